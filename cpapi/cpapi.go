@@ -6,9 +6,13 @@ import (
 	"crypto/tls"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -23,8 +27,9 @@ type CpApi struct {
 
 	httpClient *http.Client // HTTP client for making API requests
 
-	CheckPointSid          string    // SID for the Check Point session, used for authentication
-	CheckPointSidExpiresAt time.Time // Timestamp when the SID expires, used for session management
+	sidMu                  sync.Mutex // guards session login and SID, API is called from concurrent goroutines
+	CheckPointSid          string     // SID for the Check Point session, used for authentication
+	CheckPointSidExpiresAt time.Time  // Timestamp when the SID expires, used for session management
 }
 
 func (cpApi *CpApi) LoadFromConfig(cfg *config.Config) {
@@ -55,17 +60,30 @@ func NewCpApiFromConfig(cfg *config.Config) *CpApi {
 
 func (cpApi *CpApi) ApiCallWithLogin(cmd string, payload *map[string]interface{}, headers *map[string]string) (string, error) {
 
+	cpApi.sidMu.Lock()
 	if cpApi.CheckPointSid == "" || time.Now().After(cpApi.CheckPointSidExpiresAt) {
 		fmt.Println("[CPAPI] SID is empty or expired, logging in...")
-		_, err := cpApi.Login()
+		_, err := cpApi.login()
 		if err != nil {
+			cpApi.sidMu.Unlock()
 			return "", fmt.Errorf("failed to login to Check Point API: %w", err)
 		}
 	}
-	return cpApi.ApiCall(cmd, payload, headers)
+	sid := cpApi.CheckPointSid
+	cpApi.sidMu.Unlock()
+
+	return cpApi.apiCall(cmd, payload, headers, sid)
 }
 
 func (cpApi *CpApi) ApiCall(cmd string, payload *map[string]interface{}, headers *map[string]string) (string, error) {
+	cpApi.sidMu.Lock()
+	sid := cpApi.CheckPointSid
+	cpApi.sidMu.Unlock()
+
+	return cpApi.apiCall(cmd, payload, headers, sid)
+}
+
+func (cpApi *CpApi) apiCall(cmd string, payload *map[string]interface{}, headers *map[string]string, sid string) (string, error) {
 
 	url := cpApi.Url + cmd
 
@@ -86,9 +104,9 @@ func (cpApi *CpApi) ApiCall(cmd string, payload *map[string]interface{}, headers
 
 	req.Header.Add("Content-Type", "application/json")
 
-	if cpApi.CheckPointSid != "" {
-		req.Header.Add("X-chkp-sid", cpApi.CheckPointSid)
-		// fmt.Println("Using SID:", cpApi.CheckPointSid)
+	if sid != "" {
+		req.Header.Add("X-chkp-sid", sid)
+		// fmt.Println("Using SID:", sid)
 	}
 
 	if headers != nil {
@@ -134,12 +152,20 @@ type LoginResponse struct {
 }
 
 func (cpApi *CpApi) Login() (*LoginResponse, error) {
+	cpApi.sidMu.Lock()
+	defer cpApi.sidMu.Unlock()
+
+	return cpApi.login()
+}
+
+// login expects sidMu to be held by caller
+func (cpApi *CpApi) login() (*LoginResponse, error) {
 	payload := map[string]interface{}{
 		"api-key":         cpApi.CheckPointApiKey,
 		"session-name":    "cpfeedman-session",
 		"session-timeout": 60 * 60, // 1 hour
 	}
-	resp, err := cpApi.ApiCall("login", &payload, nil)
+	resp, err := cpApi.apiCall("login", &payload, nil, "")
 	if err != nil {
 		return nil, fmt.Errorf("failed to login to Check Point API: %w", err)
 	}
@@ -163,9 +189,16 @@ func (cpApi *CpApi) Login() (*LoginResponse, error) {
 
 }
 
+// Logout ends API session, does nothing when not logged in
 func (cpApi *CpApi) Logout() (string, error) {
+	cpApi.sidMu.Lock()
+	defer cpApi.sidMu.Unlock()
 
-	resp, err := cpApi.ApiCall("logout", nil, nil)
+	if cpApi.CheckPointSid == "" {
+		return "", nil
+	}
+
+	resp, err := cpApi.apiCall("logout", nil, nil, cpApi.CheckPointSid)
 	if err != nil {
 		return "", fmt.Errorf("failed to logout to Check Point API: %w", err)
 	}
@@ -273,6 +306,66 @@ func (cpApi *CpApi) FeedNames() ([]string, error) {
 	return feedNames, nil
 }
 
+// feed UID -> feed name
+func (cpApi *CpApi) FeedNamesByUID() (map[string]string, error) {
+	payload := map[string]interface{}{
+		"limit":         500,        // Adjust limit as needed
+		"details-level": "standard", // Use "full" for more details
+	}
+	resp, err := cpApi.ApiCallWithLogin("show-network-feeds", &payload, nil)
+	if err != nil {
+		return map[string]string{}, fmt.Errorf("failed to show feeds: %w", err)
+	}
+
+	var feedsResp ShowNetworkFeedsResponse
+	err = json.Unmarshal([]byte(resp), &feedsResp)
+	if err != nil {
+		return map[string]string{}, fmt.Errorf("failed to unmarshal feeds response: %w", err)
+	}
+
+	feedNames := make(map[string]string, len(feedsResp.Objects))
+	for _, feed := range feedsResp.Objects {
+		feedNames[feed.UID] = feed.Name
+	}
+
+	return feedNames, nil
+}
+
+type ShowGroupResponse struct {
+	UID     string `json:"uid"`
+	Name    string `json:"name"`
+	Members []struct {
+		UID  string `json:"uid"`
+		Name string `json:"name"`
+		Type string `json:"type"`
+	} `json:"members"`
+}
+
+// names of group members, e.g. gateways of gateway group
+func (cpApi *CpApi) GroupMembers(groupName string) ([]string, error) {
+	payload := map[string]interface{}{
+		"name":          groupName,
+		"details-level": "standard", // Use "full" for more details
+	}
+	resp, err := cpApi.ApiCallWithLogin("show-group", &payload, nil)
+	if err != nil {
+		return []string{}, fmt.Errorf("failed to show group %s: %w", groupName, err)
+	}
+
+	var groupResp ShowGroupResponse
+	err = json.Unmarshal([]byte(resp), &groupResp)
+	if err != nil {
+		return []string{}, fmt.Errorf("failed to unmarshal group response: %w", err)
+	}
+
+	memberNames := make([]string, 0, len(groupResp.Members))
+	for _, member := range groupResp.Members {
+		memberNames = append(memberNames, member.Name)
+	}
+
+	return memberNames, nil
+}
+
 type RunScriptResponse struct {
 	Tasks []struct {
 		Target string `json:"target"`
@@ -293,6 +386,20 @@ func (resp *RunScriptResponse) GetTaskIds() []string {
 	return taskIds
 }
 
+// task ID -> target gateway name
+func (resp *RunScriptResponse) GetTaskTargets() map[string]string {
+	if resp == nil || len(resp.Tasks) == 0 {
+		return map[string]string{}
+	}
+
+	taskTargets := make(map[string]string, len(resp.Tasks))
+	for _, task := range resp.Tasks {
+		taskTargets[task.TaskID] = task.Target
+	}
+
+	return taskTargets
+}
+
 func (cpApi *CpApi) RunScript(script string, scriptName string, targets []string) (*RunScriptResponse, error) {
 	resp, err := cpApi.ApiCallWithLogin("run-script", &map[string]interface{}{
 		"script":      script,
@@ -313,6 +420,38 @@ func (cpApi *CpApi) RunScript(script string, scriptName string, targets []string
 	return &runScriptResp, nil
 }
 
+// TaskStatus as reported by show-task
+type TaskStatus string
+
+const (
+	TaskStatusInProgress         TaskStatus = "in progress"
+	TaskStatusSucceeded          TaskStatus = "succeeded"
+	TaskStatusFailed             TaskStatus = "failed"
+	TaskStatusPartiallySucceeded TaskStatus = "partially succeeded"
+)
+
+// IsFinal is true for terminal states, unknown states are considered pending
+func (s TaskStatus) IsFinal() bool {
+	switch s {
+	case TaskStatusSucceeded, TaskStatusFailed, TaskStatusPartiallySucceeded:
+		return true
+	}
+	return false
+}
+
+func (s TaskStatus) IsPending() bool {
+	return !s.IsFinal()
+}
+
+func (s TaskStatus) IsSucceeded() bool {
+	return s == TaskStatusSucceeded
+}
+
+// IsFailed is true for failed and partially succeeded tasks
+func (s TaskStatus) IsFailed() bool {
+	return s == TaskStatusFailed || s == TaskStatusPartiallySucceeded
+}
+
 type TaskDetail struct {
 	UID    string `json:"uid"`
 	Name   string `json:"name"`
@@ -322,10 +461,10 @@ type TaskDetail struct {
 		Name       string `json:"name"`
 		DomainType string `json:"domain-type"`
 	} `json:"domain"`
-	TaskID             string `json:"task-id"`
-	TaskName           string `json:"task-name"`
-	Status             string `json:"status"`
-	ProgressPercentage int    `json:"progress-percentage"`
+	TaskID             string     `json:"task-id"`
+	TaskName           string     `json:"task-name"`
+	Status             TaskStatus `json:"status"`
+	ProgressPercentage int        `json:"progress-percentage"`
 	StartTime          struct {
 		Posix   int64  `json:"posix"`
 		Iso8601 string `json:"iso-8601"`
@@ -395,20 +534,49 @@ type TaskDetail struct {
 	} `json:"available-actions"`
 }
 
-func (td *TaskDetail) GetTaskResponseMessage() string {
+// GatewayTaskResult is result of task on single gateway (one entry of task-details)
+type GatewayTaskResult struct {
+	GatewayName       string
+	GatewayID         string
+	StatusCode        string
+	StatusDescription string
+	Message           string // decoded responseMessage - script stdout
+	Error             string // decoded responseError - script stderr
+	DecodeErr         error  // set when responseMessage or responseError is not valid base64
+}
+
+// GetGatewayResults decodes results of all gateways of the task
+func (td *TaskDetail) GetGatewayResults() []GatewayTaskResult {
 	if td == nil || len(td.TaskDetails) == 0 {
-		return ""
+		return []GatewayTaskResult{}
 	}
 
-	// decode from base64 if needed
-	encodedMessage := td.TaskDetails[0].ResponseMessage
-	// fmt.Println("Encoded response message:", encodedMessage)
-	decodedMessage, err := base64.StdEncoding.DecodeString(encodedMessage)
-	if err == nil {
-		return string(decodedMessage)
+	results := make([]GatewayTaskResult, 0, len(td.TaskDetails))
+	for _, detail := range td.TaskDetails {
+		result := GatewayTaskResult{
+			GatewayName:       detail.GatewayName,
+			GatewayID:         detail.GatewayID,
+			StatusCode:        detail.StatusCode,
+			StatusDescription: detail.StatusDescription,
+		}
+
+		// decode from base64
+		decodedMessage, errMessage := base64.StdEncoding.DecodeString(detail.ResponseMessage)
+		if errMessage != nil {
+			errMessage = fmt.Errorf("failed to decode response message of %s: %w", detail.GatewayName, errMessage)
+		}
+		decodedError, errError := base64.StdEncoding.DecodeString(detail.ResponseError)
+		if errError != nil {
+			errError = fmt.Errorf("failed to decode response error of %s: %w", detail.GatewayName, errError)
+		}
+		result.Message = string(decodedMessage)
+		result.Error = string(decodedError)
+		result.DecodeErr = errors.Join(errMessage, errError)
+
+		results = append(results, result)
 	}
 
-	return ""
+	return results
 }
 
 type ShowTasksResponse struct {
@@ -416,12 +584,12 @@ type ShowTasksResponse struct {
 }
 
 // tasks by status
-func (resp *ShowTasksResponse) GetTasksByStatus() map[string]int {
+func (resp *ShowTasksResponse) GetTasksByStatus() map[TaskStatus]int {
 	if resp == nil || len(resp.Tasks) == 0 {
-		return map[string]int{}
+		return map[TaskStatus]int{}
 	}
 
-	tasksByStatus := make(map[string]int)
+	tasksByStatus := make(map[TaskStatus]int)
 	for _, task := range resp.Tasks {
 		tasksByStatus[task.Status]++
 	}
@@ -437,7 +605,7 @@ func (resp *ShowTasksResponse) GetUnfinishedTaskIds() []string {
 
 	unfinishedTaskIds := make([]string, 0)
 	for _, task := range resp.Tasks {
-		if task.Status == "in progress" {
+		if task.Status.IsPending() {
 			unfinishedTaskIds = append(unfinishedTaskIds, task.TaskID)
 		}
 	}
@@ -445,20 +613,35 @@ func (resp *ShowTasksResponse) GetUnfinishedTaskIds() []string {
 	return unfinishedTaskIds
 }
 
-// finished tasks
-func (resp *ShowTasksResponse) GetFinishedTasksDetail() []TaskDetail {
+// tasks matching status filter
+func (resp *ShowTasksResponse) filterTasks(match func(TaskStatus) bool) []TaskDetail {
 	if resp == nil || len(resp.Tasks) == 0 {
 		return []TaskDetail{}
 	}
 
-	finishedTaskDetails := make([]TaskDetail, 0)
+	taskDetails := make([]TaskDetail, 0)
 	for _, task := range resp.Tasks {
-		if task.Status == "succeeded" {
-			finishedTaskDetails = append(finishedTaskDetails, task)
+		if match(task.Status) {
+			taskDetails = append(taskDetails, task)
 		}
 	}
 
-	return finishedTaskDetails
+	return taskDetails
+}
+
+// finished tasks - succeeded, failed and partially succeeded
+func (resp *ShowTasksResponse) GetFinishedTasksDetail() []TaskDetail {
+	return resp.filterTasks(TaskStatus.IsFinal)
+}
+
+// succeeded tasks
+func (resp *ShowTasksResponse) GetSucceededTasksDetail() []TaskDetail {
+	return resp.filterTasks(TaskStatus.IsSucceeded)
+}
+
+// failed and partially succeeded tasks, see GetGatewayResults for reason
+func (resp *ShowTasksResponse) GetFailedTasksDetail() []TaskDetail {
+	return resp.filterTasks(TaskStatus.IsFailed)
 }
 
 func (cpApi *CpApi) ShowTasks(taskIds []string) (*ShowTasksResponse, error) {
@@ -480,6 +663,50 @@ func (cpApi *CpApi) ShowTasks(taskIds []string) (*ShowTasksResponse, error) {
 	return &showTasksResp, nil
 }
 
+// tasks started since given time, e.g. to detect policy installations
+func (cpApi *CpApi) ShowRecentTasks(since time.Time) (*ShowTasksResponse, error) {
+	payload := map[string]interface{}{
+		"from-date":     since.UTC().Format("2006-01-02T15:04:05"),
+		"limit":         100,        // Adjust limit as needed
+		"details-level": "standard", // Use "full" for more details
+	}
+	resp, err := cpApi.ApiCallWithLogin("show-tasks", &payload, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to show recent tasks: %w", err)
+	}
+
+	var showTasksResp ShowTasksResponse
+	err = json.Unmarshal([]byte(resp), &showTasksResp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal show tasks response: %w", err)
+	}
+
+	return &showTasksResp, nil
+}
+
+// policy installation tasks in any status
+func (resp *ShowTasksResponse) GetPolicyInstallTasks() []TaskDetail {
+	if resp == nil || len(resp.Tasks) == 0 {
+		return []TaskDetail{}
+	}
+
+	policyTasks := make([]TaskDetail, 0)
+	for _, task := range resp.Tasks {
+		if strings.HasPrefix(strings.ToLower(task.TaskName), "policy installation") {
+			policyTasks = append(policyTasks, task)
+		}
+	}
+
+	return policyTasks
+}
+
+// policy installation tasks which are not in progress anymore
+func (resp *ShowTasksResponse) GetFinishedPolicyInstallTasks() []TaskDetail {
+	return slices.DeleteFunc(resp.GetPolicyInstallTasks(), func(task TaskDetail) bool {
+		return !task.Status.IsFinal()
+	})
+}
+
 func (cpApi *CpApi) KickFeed(feed string, targets []string) (*RunScriptResponse, error) {
 	script := fmt.Sprintf("(echo '---'; date; echo \"%s\" ; dynamic_objects -efo_update \"%s\" ) | tee -a /var/log/kicked.log", feed, feed)
 	resp, err := cpApi.RunScript(script, "kick feed "+feed, targets)
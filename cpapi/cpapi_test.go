@@ -0,0 +1,159 @@
+package cpapi
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+func b64(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+func TestGetGatewayResults(t *testing.T) {
+	tests := []struct {
+		name        string
+		taskDetails string
+		want        []GatewayTaskResult
+		wantErr     []bool
+	}{
+		{
+			name:        "no task details",
+			taskDetails: `[]`,
+			want:        []GatewayTaskResult{},
+		},
+		{
+			name: "several gateways",
+			taskDetails: `[
+				{"gatewayName": "gw1", "gatewayId": "id1", "statusCode": "succeeded", "statusDescription": "done",
+				 "responseMessage": "` + b64("feed updated") + `", "responseError": ""},
+				{"gatewayName": "gw2", "gatewayId": "id2", "statusCode": "failed", "statusDescription": "script failed",
+				 "responseMessage": "", "responseError": "` + b64("feed not found") + `"}
+			]`,
+			want: []GatewayTaskResult{
+				{GatewayName: "gw1", GatewayID: "id1", StatusCode: "succeeded", StatusDescription: "done", Message: "feed updated"},
+				{GatewayName: "gw2", GatewayID: "id2", StatusCode: "failed", StatusDescription: "script failed", Error: "feed not found"},
+			},
+			wantErr: []bool{false, false},
+		},
+		{
+			name: "invalid base64 response message",
+			taskDetails: `[
+				{"gatewayName": "gw1", "statusCode": "succeeded", "responseMessage": "not base64!", "responseError": ""},
+				{"gatewayName": "gw2", "statusCode": "succeeded", "responseMessage": "` + b64("ok") + `"}
+			]`,
+			want: []GatewayTaskResult{
+				{GatewayName: "gw1", StatusCode: "succeeded"},
+				{GatewayName: "gw2", StatusCode: "succeeded", Message: "ok"},
+			},
+			wantErr: []bool{true, false},
+		},
+		{
+			name: "invalid base64 response error",
+			taskDetails: `[
+				{"gatewayName": "gw1", "statusCode": "failed", "responseMessage": "` + b64("partial output") + `", "responseError": "%%%"}
+			]`,
+			want: []GatewayTaskResult{
+				{GatewayName: "gw1", StatusCode: "failed", Message: "partial output"},
+			},
+			wantErr: []bool{true},
+		},
+	}
+	for _, tt := range tests {
+		var td TaskDetail
+		if err := json.Unmarshal([]byte(`{"task-details": `+tt.taskDetails+`}`), &td); err != nil {
+			t.Fatalf("%s: unmarshal: %v", tt.name, err)
+		}
+		got := td.GetGatewayResults()
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: GetGatewayResults() returned %d results, want %d", tt.name, len(got), len(tt.want))
+			continue
+		}
+		for i, result := range got {
+			if (result.DecodeErr != nil) != tt.wantErr[i] {
+				t.Errorf("%s: result %d DecodeErr = %v, want error %v", tt.name, i, result.DecodeErr, tt.wantErr[i])
+			}
+			result.DecodeErr = nil
+			if result != tt.want[i] {
+				t.Errorf("%s: result %d = %+v, want %+v", tt.name, i, result, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestGetGatewayResultsNil(t *testing.T) {
+	var td *TaskDetail
+	if got := td.GetGatewayResults(); len(got) != 0 {
+		t.Errorf("nil GetGatewayResults() = %+v, want empty", got)
+	}
+}
+
+func TestTaskStatus(t *testing.T) {
+	tests := []struct {
+		status                                            TaskStatus
+		wantFinal, wantPending, wantSucceeded, wantFailed bool
+	}{
+		{TaskStatusInProgress, false, true, false, false},
+		{TaskStatusSucceeded, true, false, true, false},
+		{TaskStatusFailed, true, false, false, true},
+		{TaskStatusPartiallySucceeded, true, false, false, true},
+		{"queued", false, true, false, false},
+	}
+	for _, tt := range tests {
+		if got := tt.status.IsFinal(); got != tt.wantFinal {
+			t.Errorf("%q.IsFinal() = %v, want %v", tt.status, got, tt.wantFinal)
+		}
+		if got := tt.status.IsPending(); got != tt.wantPending {
+			t.Errorf("%q.IsPending() = %v, want %v", tt.status, got, tt.wantPending)
+		}
+		if got := tt.status.IsSucceeded(); got != tt.wantSucceeded {
+			t.Errorf("%q.IsSucceeded() = %v, want %v", tt.status, got, tt.wantSucceeded)
+		}
+		if got := tt.status.IsFailed(); got != tt.wantFailed {
+			t.Errorf("%q.IsFailed() = %v, want %v", tt.status, got, tt.wantFailed)
+		}
+	}
+}
+
+func taskIDs(tasks []TaskDetail) []string {
+	ids := make([]string, 0, len(tasks))
+	for _, task := range tasks {
+		ids = append(ids, task.TaskID)
+	}
+	return ids
+}
+
+func TestShowTasksResponseFilters(t *testing.T) {
+	var resp ShowTasksResponse
+	err := json.Unmarshal([]byte(`{"tasks": [
+		{"task-id": "t1", "status": "succeeded"},
+		{"task-id": "t2", "status": "failed"},
+		{"task-id": "t3", "status": "partially succeeded"},
+		{"task-id": "t4", "status": "in progress"}
+	]}`), &resp)
+	if err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		got  []string
+		want []string
+	}{
+		{"GetFinishedTasksDetail", taskIDs(resp.GetFinishedTasksDetail()), []string{"t1", "t2", "t3"}},
+		{"GetSucceededTasksDetail", taskIDs(resp.GetSucceededTasksDetail()), []string{"t1"}},
+		{"GetFailedTasksDetail", taskIDs(resp.GetFailedTasksDetail()), []string{"t2", "t3"}},
+		{"GetUnfinishedTaskIds", resp.GetUnfinishedTaskIds(), []string{"t4"}},
+	}
+	for _, tt := range tests {
+		if !slices.Equal(tt.got, tt.want) {
+			t.Errorf("%s() = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+
+	byStatus := resp.GetTasksByStatus()
+	if byStatus[TaskStatusFailed] != 1 || byStatus[TaskStatusPartiallySucceeded] != 1 {
+		t.Errorf("GetTasksByStatus() = %v, want one failed and one partially succeeded", byStatus)
+	}
+}
@@ -0,0 +1,169 @@
+package cpapi
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+)
+
+// WaitForTasks polls show-task until all tasks reach final state or ctx is done
+
+// Backoff between show-task polls
+type Backoff struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+}
+
+var DefaultBackoff = Backoff{Initial: 1 * time.Second, Max: 10 * time.Second, Multiplier: 1.5}
+
+// Next returns delay following d
+func (b Backoff) Next(d time.Duration) time.Duration {
+	if d <= 0 {
+		return b.Initial
+	}
+	next := time.Duration(float64(d) * b.Multiplier)
+	if b.Max > 0 && next > b.Max {
+		return b.Max
+	}
+	return next
+}
+
+// TaskProgress is reported to progress callback after each poll
+type TaskProgress struct {
+	Finished   int          // tasks in final state
+	Total      int          // all waited tasks
+	Percentage int          // average progress-percentage of all tasks
+	Tasks      []TaskDetail // tasks as seen by last poll
+}
+
+type WaitOptions struct {
+	Backoff              Backoff
+	MaxConsecutiveErrors int                // show-task failures tolerated in a row, 0 means 3
+	OnProgress           func(TaskProgress) // optional
+}
+
+// TaskOutcome is last seen state of waited task
+type TaskOutcome struct {
+	TaskDetail
+	FinishedAfter time.Duration // since wait started, 0 while task is not finished
+}
+
+// TasksResult aggregates outcome of waited tasks in order of task IDs
+type TasksResult struct {
+	Tasks    []TaskOutcome
+	Duration time.Duration
+}
+
+func (r *TasksResult) filter(match func(TaskStatus) bool) []TaskOutcome {
+	outcomes := make([]TaskOutcome, 0)
+	if r == nil {
+		return outcomes
+	}
+	for _, task := range r.Tasks {
+		if match(task.Status) {
+			outcomes = append(outcomes, task)
+		}
+	}
+	return outcomes
+}
+
+func (r *TasksResult) Succeeded() []TaskOutcome {
+	return r.filter(TaskStatus.IsSucceeded)
+}
+
+// Failed returns failed and partially succeeded tasks
+func (r *TasksResult) Failed() []TaskOutcome {
+	return r.filter(TaskStatus.IsFailed)
+}
+
+// Unfinished returns tasks which did not reach final state
+func (r *TasksResult) Unfinished() []TaskOutcome {
+	return r.filter(TaskStatus.IsPending)
+}
+
+// WaitForTasks waits for tasks to finish
+// result is returned also when ctx ends first, together with ctx error - unfinished tasks are pending in result
+func (cpApi *CpApi) WaitForTasks(ctx context.Context, taskIds []string, opts *WaitOptions) (*TasksResult, error) {
+	if opts == nil {
+		opts = &WaitOptions{}
+	}
+	backoff := opts.Backoff
+	if backoff.Initial <= 0 {
+		backoff = DefaultBackoff
+	}
+	maxErrors := opts.MaxConsecutiveErrors
+	if maxErrors <= 0 {
+		maxErrors = 3
+	}
+
+	startTime := time.Now()
+	result := &TasksResult{Tasks: make([]TaskOutcome, len(taskIds))}
+	index := make(map[string]int, len(taskIds))
+	for i, taskId := range taskIds {
+		result.Tasks[i].TaskID = taskId
+		index[taskId] = i
+	}
+
+	unfinishedTasks := taskIds
+	errCount := 0
+	delay := time.Duration(0)
+	for len(unfinishedTasks) > 0 {
+		delay = backoff.Next(delay)
+		select {
+		case <-ctx.Done():
+			result.Duration = time.Since(startTime)
+			return result, ctx.Err()
+		case <-time.After(delay):
+		}
+
+		taskRes, err := cpApi.ShowTasks(unfinishedTasks)
+		if err != nil {
+			errCount++
+			if errCount >= maxErrors {
+				result.Duration = time.Since(startTime)
+				return result, err
+			}
+			fmt.Fprintf(os.Stderr, "[CPAPI] Failed to poll tasks (%d/%d): %v\n", errCount, maxErrors, err)
+			continue
+		}
+		errCount = 0
+
+		for _, task := range taskRes.Tasks {
+			i, ok := index[task.TaskID]
+			if !ok {
+				continue
+			}
+			result.Tasks[i].TaskDetail = task
+			if task.Status.IsFinal() {
+				result.Tasks[i].FinishedAfter = time.Since(startTime)
+			}
+		}
+
+		unfinishedTasks = unfinishedTasks[:0:0]
+		for _, task := range result.Unfinished() {
+			unfinishedTasks = append(unfinishedTasks, task.TaskID)
+		}
+
+		if opts.OnProgress != nil {
+			progress := TaskProgress{Total: len(taskIds), Tasks: taskRes.Tasks}
+			percentage := 0
+			for _, task := range result.Tasks {
+				if task.Status.IsFinal() {
+					progress.Finished++
+					percentage += 100
+				} else {
+					percentage += task.ProgressPercentage
+				}
+			}
+			if len(taskIds) > 0 {
+				progress.Percentage = percentage / len(taskIds)
+			}
+			opts.OnProgress(progress)
+		}
+	}
+
+	result.Duration = time.Since(startTime)
+	return result, nil
+}
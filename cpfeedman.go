@@ -1,28 +1,44 @@
 package main
 
 import (
+	"context"
+	"cpfeedman/amqpin"
 	"cpfeedman/config"
 	"cpfeedman/cpapi"
+	"cpfeedman/dispatch"
+	"cpfeedman/feedmap"
+	"cpfeedman/filein"
+	"cpfeedman/input"
+	"cpfeedman/kafkain"
+	"cpfeedman/kicker"
+	"cpfeedman/mqttin"
+	"cpfeedman/natsin"
+	"cpfeedman/notification"
+	"cpfeedman/redisin"
 	"cpfeedman/sqsin"
+	"cpfeedman/webhook"
 	"fmt"
+	"maps"
 	"os"
+	"os/signal"
+	"slices"
+	"syscall"
 	"time"
-
-	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
 )
 
 const version = "v0.1.0"
 
+// time to release messages of kicks still running when shutdown timeout passed
+const shutdownReleaseTimeout = 3 * time.Second
+
 // global config variable
 var cfg config.Config
 
 // CP API client
 var cpApi *cpapi.CpApi
 
-var notifiedGateways []string = []string{
-	"gw10", // example gateway names, replace with actual gateway names
-	// "gw20",
-}
+// notification scope - empty means all gateways enforcing the feed
+var notifiedGateways []string
 
 // init configuration and more
 func init() {
@@ -34,70 +50,36 @@ func init() {
 		notifiedGateways = cfg.CpFeedManNotifiedGateways
 		fmt.Fprintf(os.Stdout, "[Config] Notified gateways: %v\n", notifiedGateways)
 	} else {
-		fmt.Fprintln(os.Stderr, "[Config] No notified gateways configured. Using default:", notifiedGateways)
+		fmt.Fprintln(os.Stderr, "[Config] No notified gateways configured. Notifying all gateways enforcing the feed.")
 	}
 
 }
 
-// check active feeds on each gateway
-func mapFeedsOnGateways(gwNames []string) {
-
-	fmt.Fprintln(os.Stdout, "[FeedMap] Mapping active feeds on each gateway. This may take a while, please wait...")
-
-	// execute mapping active feeds on each gateway
-	resp, err := cpApi.RunScript("(date; hostname; dynamic_objects -efo_show | grep -Po '^object name : \\K.*') | tee -a /var/log/cpfeedman.log", "map feeds", gwNames)
+// gateways in notification scope to be mapped by feed map refresher
+func mappedGateways() ([]string, error) {
+	gwNames, err := cpApi.GatewayNames()
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Error running script on Check Point API:", err)
-		os.Exit(1)
+		return nil, err
 	}
-	// fmt.Fprintln(os.Stdout, "RunScript response:", resp.GetTaskIds())
-
-	unfinishedTasks := resp.GetTaskIds()
-
-	loopStartTime := time.Now()
-	for len(unfinishedTasks) > 0 {
-		// fmt.Fprintln(os.Stdout, "Waiting for tasks to finish:", unfinishedTasks)
-		time.Sleep(1 * time.Second)
-
-		taskRes, err := cpApi.ShowTasks(resp.GetTaskIds())
-		if err != nil {
-			fmt.Fprintln(os.Stderr, "Error getting task results from Check Point API:", err)
-			os.Exit(1)
-		}
-		// fmt.Fprintln(os.Stdout, "GetTaskResults response:", taskRes)
-		// fmt.Fprintln(os.Stdout, "Tasks by status:", taskRes.GetTasksByStatus())
-
-		// response message for finished tasks
-		finishedTasks := taskRes.GetFinishedTasksDetail()
-		// fmt.Fprintln(os.Stdout, "Finished tasks:", finishedTasks)
-		for _, taskDetail := range finishedTasks {
-			// fmt.Println("FINISHED Task ID:", taskDetail.TaskID)
-			responseMessage := taskDetail.GetTaskResponseMessage()
-			// fmt.Println("Response message:", responseMessage)
-			if responseMessage != "" {
-				fmt.Fprintf(os.Stdout, "\n[FeedMap] Task %s finished with message:\n===\n%s===\n", taskDetail.TaskID, responseMessage)
-			}
-		}
-
-		unfinishedTasks = taskRes.GetUnfinishedTaskIds()
-		if len(unfinishedTasks) == 0 {
-			fmt.Fprintln(os.Stdout, "[FeedMap] All tasks finished successfully.")
-			break
-		}
-
-		if time.Since(loopStartTime) > 2*time.Minute { //
-			fmt.Fprintln(os.Stderr, "[FeedMap] Timeout waiting for tasks to finish. Exiting.")
-			break
-		}
-	}
-
+	return dispatch.GatewaysInScope(gwNames, notifiedGateways), nil
 }
 
 func main() {
 	fmt.Fprintln(os.Stdout, "cpfeedman version", version)
 
+	// SIGINT or SIGTERM starts graceful shutdown
+	ctx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+
 	fmt.Fprintln(os.Stdout, "Check Point Management Server:", cfg.CheckPointServer)
 
+	s3Rules, err := notification.ParseS3Rules(cfg.CpFeedManS3FeedRules)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "[Config] Error parsing S3 feed rules:", err)
+		os.Exit(1)
+	}
+	decoder := notification.NewDecoder(s3Rules, cfg.CpFeedManCloudEventTypes)
+
 	// retrieve all gateways and feeds
 	gwNames, err := cpApi.GatewayNames()
 	if err != nil {
@@ -106,63 +88,188 @@ func main() {
 	}
 	fmt.Fprintln(os.Stdout, "gwNames:", gwNames)
 
-	feedNames, err := cpApi.FeedNames()
+	feedNamesByUID, err := cpApi.FeedNamesByUID()
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "Error fetching from Check Point API:", err)
 		os.Exit(1)
 	}
-	fmt.Fprintln(os.Stdout, "feedNames:", feedNames)
+	fmt.Fprintln(os.Stdout, "feedNames:", slices.Sorted(maps.Values(feedNamesByUID)))
 
 	fmt.Fprintln(os.Stdout, "")
-	mapFeedsOnGateways(gwNames)
+	feedMap, err := feedmap.Build(ctx, cpApi, dispatch.GatewaysInScope(gwNames, notifiedGateways))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error mapping feeds on gateways:", err)
+		os.Exit(1)
+	}
+	feedMap.SetFeedNames(feedNamesByUID)
+	fmt.Fprintf(os.Stdout, "[FeedMap] Active feeds by gateway:\n%s", feedMap)
+
+	// keep feed map up to date in background
+	refresher := feedmap.NewRefresher(cpApi, feedMap, mappedGateways, cfg.CpFeedManFeedMapRefreshInterval)
+	refresher.PolicyPollInterval = cfg.CpFeedManPolicyPollInterval
+	refresher.Start(ctx)
+
+	// notifications of the same feed within debounce window result in single kick
+	// and kicks are rate limited per feed and gateway
+	// on shutdown no more kicks are started, running ones are followed until shutdown timeout
+	tracking, stopTracking := context.WithCancel(context.Background())
+	defer stopTracking()
+	limiter := kicker.NewLimiter(cfg.CpFeedManFeedKicksPerMinute, cfg.CpFeedManGatewayConcurrency, cfg.CpFeedManKickBacklog)
+	kick := kicker.NewKickFunc(tracking, cpApi, cfg.CpFeedManKickTimeout)
+	coalescer := kicker.NewCoalescer(ctx, cfg.CpFeedManDebounceWindow, limiter.Wrap(kick))
+	fmt.Fprintln(os.Stdout, "[Config] Debounce window:", coalescer.Window)
+	fmt.Fprintf(os.Stdout, "[Config] Limits: %d kicks per feed per minute, %d concurrent kicks per gateway, backlog %d\n",
+		limiter.FeedKicksPerMinute, limiter.GatewayConcurrency, limiter.Backlog)
+
+	dispatcher := &dispatch.Dispatcher{
+		Context:          ctx,
+		Decoder:          decoder,
+		FeedMap:          feedMap,
+		Coalescer:        coalescer,
+		CpApi:            cpApi,
+		NotifiedGateways: notifiedGateways,
+	}
 
-	// logoutResponse, err := cpApi.Logout()
-	// if err != nil {
-	// 	fmt.Fprintln(os.Stderr, "Error logging out from Check Point API:", err)
-	// 	os.Exit(1)
-	// }
-	// fmt.Fprintln(os.Stdout, "Logout response:", logoutResponse)
+	// input sources
+	inputs := input.NewGroup()
+	for _, queueUrl := range cfg.CpFeedManSqsEndpoints {
+		sqsIn := sqsin.NewSQSIn(queueUrl)
+		sqsIn.Workers = cfg.CpFeedManSqsWorkers
+		sqsIn.OrderKey = dispatcher.FeedKey
+		sqsIn.Submit = dispatcher.SubmitMessage
+		sqsIn.RetryDelay = cfg.CpFeedManSqsRetryDelay
+		sqsIn.MaxRetryDelay = cfg.CpFeedManSqsMaxRetryDelay
+		sqsIn.DedupWindow = cfg.CpFeedManSqsDedupWindow
+		inputs.Add(sqsIn)
+	}
+	if cfg.CpFeedManAmqpUrl != "" {
+		if cfg.CpFeedManAmqpQueue == "" {
+			fmt.Fprintln(os.Stderr, "[Config] CPFEEDMAN_AMQP_QUEUE is required for AMQP input")
+			os.Exit(1)
+		}
+		amqpIn := amqpin.NewAMQPIn(cfg.CpFeedManAmqpUrl, cfg.CpFeedManAmqpQueue)
+		amqpIn.Prefetch = cfg.CpFeedManAmqpPrefetch
+		inputs.Add(amqpIn)
+	}
+	if cfg.CpFeedManMqttBroker != "" {
+		topicRules, err := mqttin.ParseTopicRules(cfg.CpFeedManMqttTopics)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "[Config] Error parsing MQTT topic rules:", err)
+			os.Exit(1)
+		}
+		mqttIn := mqttin.NewMQTTIn(cfg.CpFeedManMqttBroker, cfg.CpFeedManMqttClientId, topicRules)
+		mqttIn.Username = cfg.CpFeedManMqttUsername
+		mqttIn.Password = cfg.CpFeedManMqttPassword
+		if cfg.CpFeedManMqttTLSCA != "" || cfg.CpFeedManMqttTLSCert != "" {
+			mqttIn.TLSConfig, err = mqttin.NewTLSConfig(cfg.CpFeedManMqttTLSCA, cfg.CpFeedManMqttTLSCert, cfg.CpFeedManMqttTLSKey)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "[Config] Error loading MQTT TLS configuration:", err)
+				os.Exit(1)
+			}
+		}
+		inputs.Add(mqttIn)
+	}
+	if len(cfg.CpFeedManKafkaBrokers) > 0 {
+		topics, topicFeeds, err := kafkain.ParseTopics(cfg.CpFeedManKafkaTopics)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "[Config] Error parsing Kafka topics:", err)
+			os.Exit(1)
+		}
+		if len(topics) == 0 {
+			fmt.Fprintln(os.Stderr, "[Config] CPFEEDMAN_KAFKA_TOPICS is required for Kafka input")
+			os.Exit(1)
+		}
+		kafkaIn := kafkain.NewKafkaIn(cfg.CpFeedManKafkaBrokers, cfg.CpFeedManKafkaGroup, topics)
+		kafkaIn.TopicFeeds = topicFeeds
+		inputs.Add(kafkaIn)
+	}
+	if cfg.CpFeedManNatsUrl != "" {
+		if cfg.CpFeedManNatsStream == "" {
+			fmt.Fprintln(os.Stderr, "[Config] CPFEEDMAN_NATS_STREAM is required for NATS input")
+			os.Exit(1)
+		}
+		subjectRules, err := natsin.ParseSubjectRules(cfg.CpFeedManNatsSubjects)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "[Config] Error parsing NATS subject rules:", err)
+			os.Exit(1)
+		}
+		natsIn := natsin.NewNATSIn(cfg.CpFeedManNatsUrl, cfg.CpFeedManNatsStream, cfg.CpFeedManNatsConsumer, subjectRules)
+		natsIn.CredsFile = cfg.CpFeedManNatsCreds
+		inputs.Add(natsIn)
+	}
+	if cfg.CpFeedManRedisUrl != "" {
+		redisIn := redisin.NewRedisIn(cfg.CpFeedManRedisUrl, cfg.CpFeedManRedisStream, cfg.CpFeedManRedisGroup)
+		if cfg.CpFeedManRedisConsumer != "" {
+			redisIn.Consumer = cfg.CpFeedManRedisConsumer
+		}
+		redisIn.DeadLetterStream = cfg.CpFeedManRedisDeadLetterStream
+		redisIn.ClaimIdle = cfg.CpFeedManRedisClaimIdle
+		inputs.Add(redisIn)
+	}
+	if len(cfg.CpFeedManWatchPaths) > 0 {
+		pathRules, err := filein.ParsePathRules(cfg.CpFeedManWatchPaths)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "[Config] Error parsing watch path rules:", err)
+			os.Exit(1)
+		}
+		fileIn := filein.NewFileIn(pathRules)
+		fileIn.Settle = cfg.CpFeedManWatchSettle
+		inputs.Add(fileIn)
+	}
+	if cfg.CpFeedManWebhookAddr != "" {
+		hook := webhook.NewWebhook(cfg.CpFeedManWebhookAddr)
+		hook.Token = cfg.CpFeedManWebhookToken
+		hook.HMACSecret = cfg.CpFeedManWebhookHMACSecret
+		hook.TLSCertFile = cfg.CpFeedManWebhookTLSCert
+		hook.TLSKeyFile = cfg.CpFeedManWebhookTLSKey
+		inputs.Add(hook)
+	}
 
 	fmt.Fprintln(os.Stdout, "")
-	fmt.Fprintln(os.Stdout, "[SQS] Listening for SQS messages")
-
-	sqsIn := sqsin.NewSQSIn(cfg.CpFeedManSqsEndpoint)
-
-	sqsIn.OnMessage = func(msg *types.Message) {
-		fmt.Fprintf(os.Stdout, "\n")
-		fmt.Fprintf(os.Stdout, "[SQS] CALLBACK Received message: %s\n", *msg.Body)
-
-		// Here you can process the message, e.g., parse it, run scripts, etc.
+	fmt.Fprintln(os.Stdout, "[Input] Listening for notifications")
 
-		// is msg.Body in feedNames?
-		if msg.Body != nil {
-			for _, feedName := range feedNames {
-				if *msg.Body == feedName {
-					fmt.Fprintf(os.Stdout, "[SQS] Message body '%s' matches feed name '%s'.\n", *msg.Body, feedName)
+	listenErr := make(chan error, 1)
+	go func() {
+		listenErr <- inputs.Listen(dispatcher.HandleMessage)
+	}()
 
-					// TODO rate limiting
-					// TODO feed map - ask only relevant gateways (vs all)
-					res, err := cpApi.KickFeed(feedName, notifiedGateways)
-					if err != nil {
-						fmt.Fprintf(os.Stderr, "[SQS] Error kicking feed '%s': %v\n", feedName, err)
-						continue
-					} else {
-						fmt.Fprintf(os.Stdout, "[SQS] Kick feed response for '%s': %s\n", feedName, res)
-					}
-
-					fmt.Fprintf(os.Stdout, "[SQS] Kicked feed '%s'.\n", feedName)
-
-				}
+	exitCode := 0
+	select {
+	case err := <-listenErr:
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "[Input] Error listening for notifications:", err)
+			exitCode = 1
+		}
+	case <-ctx.Done():
+		// second signal kills immediately
+		stopSignals()
+		fmt.Fprintf(os.Stdout, "[Shutdown] Stopping inputs, waiting up to %s for messages being handled\n", cfg.CpFeedManShutdownTimeout)
+		inputs.Stop()
+
+		select {
+		case err := <-listenErr:
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "[Input] Error listening for notifications:", err)
+			}
+			fmt.Fprintln(os.Stdout, "[Shutdown] All inputs stopped")
+		case <-time.After(cfg.CpFeedManShutdownTimeout):
+			fmt.Fprintln(os.Stderr, "[Shutdown] Kicks still running after timeout, releasing their messages")
+			stopTracking()
+			exitCode = 1
+
+			select {
+			case <-listenErr:
+			case <-time.After(shutdownReleaseTimeout):
+				fmt.Fprintln(os.Stderr, "[Shutdown] Messages still being handled, they will be redelivered")
 			}
-		} else {
-			fmt.Fprintln(os.Stderr, "[SQS] Received message with nil body.")
 		}
-
-		fmt.Fprintf(os.Stdout, "\n")
 	}
 
-	if err := sqsIn.Listen(); err != nil {
-		fmt.Fprintln(os.Stderr, "[SQS] Error listening on SQS:", err)
-		os.Exit(1)
+	logoutResponse, err := cpApi.Logout()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "[Shutdown] Error logging out from Check Point API:", err)
+	} else if logoutResponse != "" {
+		fmt.Fprintln(os.Stdout, "[Shutdown] Logout response:", logoutResponse)
 	}
+	os.Exit(exitCode)
 }
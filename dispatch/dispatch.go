@@ -0,0 +1,191 @@
+package dispatch
+
+import (
+	"context"
+	"cpfeedman/cpapi"
+	"cpfeedman/feedmap"
+	"cpfeedman/input"
+	"cpfeedman/kicker"
+	"cpfeedman/notification"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Dispatcher is the single handler of messages from all input sources
+// it decodes notifications, resolves feed and gateways to kick and waits for the kick
+// once Context is cancelled (shutdown), notifications are retried without kick
+
+type Dispatcher struct {
+	Context          context.Context
+	Decoder          *notification.Decoder
+	FeedMap          *feedmap.FeedMap
+	Coalescer        *kicker.Coalescer
+	CpApi            *cpapi.CpApi
+	NotifiedGateways []string // notification scope - empty means all gateways enforcing the feed
+}
+
+// GatewaysInScope returns gateways from gwNames which are in scope (all if scope is empty)
+func GatewaysInScope(gwNames []string, scope []string) []string {
+	if len(scope) == 0 {
+		return gwNames
+	}
+
+	inScope := make([]string, 0, len(gwNames))
+	for _, gw := range gwNames {
+		if slices.Contains(scope, gw) {
+			inScope = append(inScope, gw)
+		}
+	}
+
+	return inScope
+}
+
+// HandleMessage decodes message from input and handles its notifications, implements input.Handler
+func (d *Dispatcher) HandleMessage(msg *input.Message) input.Outcome {
+	return d.SubmitMessage(msg)()
+}
+
+// SubmitMessage decodes message from input and submits kicks of its notifications, returned function
+// waits for the kicks, implements input.Submitter
+// messages submitted one after another are merged into the same kick while waiting concurrently
+func (d *Dispatcher) SubmitMessage(msg *input.Message) func() input.Outcome {
+	fmt.Fprintf(os.Stdout, "\n")
+	defer fmt.Fprintf(os.Stdout, "\n")
+
+	fmt.Fprintf(os.Stdout, "[%s] CALLBACK Received message: %s\n", msg.Source, msg.Body)
+
+	notifications, err := d.Decoder.Decode(msg.Body, msg.Attributes)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[%s] Invalid notification: %v\n", msg.Source, err)
+		return handled(input.Reject)
+	}
+	if len(notifications) == 0 {
+		fmt.Fprintf(os.Stdout, "[%s] Message does not ask for any kick.\n", msg.Source)
+		return handled(input.Ack)
+	}
+
+	waits := make([]func() input.Outcome, 0, len(notifications))
+	for _, n := range notifications {
+		waits = append(waits, d.SubmitNotification(n))
+	}
+
+	// all notifications of a message are handled, the most severe outcome wins
+	return func() input.Outcome {
+		outcome := input.Ack
+		for _, wait := range waits {
+			if res := wait(); res == input.Retry || (res == input.Reject && outcome == input.Ack) {
+				outcome = res
+			}
+		}
+		return outcome
+	}
+}
+
+// handled returns wait function of message handled without kick
+func handled(outcome input.Outcome) func() input.Outcome {
+	return func() input.Outcome { return outcome }
+}
+
+// FeedKey returns names of feeds notified by message, used to keep notifications of a feed in order
+// empty for invalid message
+func (d *Dispatcher) FeedKey(msg *input.Message) string {
+	notifications, err := d.Decoder.Decode(msg.Body, msg.Attributes)
+	if err != nil {
+		return ""
+	}
+
+	feeds := make([]string, 0, len(notifications))
+	for _, n := range notifications {
+		feed := d.resolveFeed(n)
+		if feed == "" {
+			feed = n.Feed + n.FeedUID
+		}
+		if !slices.Contains(feeds, feed) {
+			feeds = append(feeds, feed)
+		}
+	}
+	slices.Sort(feeds)
+	return strings.Join(feeds, ",")
+}
+
+// HandleNotification kicks feed of notification and waits for result
+func (d *Dispatcher) HandleNotification(n *notification.Notification) input.Outcome {
+	return d.SubmitNotification(n)()
+}
+
+// SubmitNotification schedules kick of feed of notification, returned function waits for result
+func (d *Dispatcher) SubmitNotification(n *notification.Notification) func() input.Outcome {
+	fmt.Fprintf(os.Stdout, "[Dispatch] Notification: %s\n", n)
+
+	if d.Context.Err() != nil {
+		fmt.Fprintf(os.Stderr, "[Dispatch] Shutting down, notification will be retried: %s\n", n)
+		return handled(input.Retry)
+	}
+
+	feedName := d.resolveFeed(n)
+	if feedName == "" {
+		fmt.Fprintf(os.Stderr, "[Dispatch] Notification does not match any feed: %s\n", n)
+		return handled(input.Reject)
+	}
+
+	targets, err := d.resolveTargets(feedName, n)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[Dispatch] Error resolving gateways of feed '%s': %v\n", feedName, err)
+		return handled(input.Retry)
+	}
+	if len(targets) == 0 {
+		fmt.Fprintf(os.Stdout, "[Dispatch] Feed '%s' is not enforced on any notified gateway. Skipping.\n", feedName)
+		return handled(input.Ack)
+	}
+
+	// wait for kick, notification is acknowledged only if kick succeeded
+	fmt.Fprintf(os.Stdout, "[Dispatch] Scheduling kick of feed '%s' on gateways: %v\n", feedName, targets)
+	var resCh <-chan kicker.Result
+	if n.Priority == notification.PriorityHigh {
+		resCh = d.Coalescer.SubmitUrgent(feedName, targets)
+	} else {
+		resCh = d.Coalescer.Submit(feedName, targets)
+	}
+	return func() input.Outcome {
+		res := <-resCh
+		if res.Report != nil {
+			fmt.Fprintf(os.Stdout, "[Dispatch] Kick of feed '%s' finished in %s on %d gateways, %d failed (merged %d notifications, correlation-id '%s')\n",
+				feedName, res.Report.Duration.Round(time.Millisecond), len(res.Report.Gateways), len(res.Report.Failed()), res.Merged, n.CorrelationID)
+		}
+		if res.Err != nil {
+			fmt.Fprintf(os.Stderr, "[Dispatch] Kick of feed '%s' failed, notification will be retried: %v\n", feedName, res.Err)
+			return input.Retry
+		}
+
+		return input.Ack
+	}
+}
+
+// feed name of notification, empty if feed is unknown
+func (d *Dispatcher) resolveFeed(n *notification.Notification) string {
+	return d.FeedMap.FeedName(n.Feed, n.FeedUID)
+}
+
+// gateways to kick - enforcing the feed, in scope and requested by notification
+func (d *Dispatcher) resolveTargets(feedName string, n *notification.Notification) ([]string, error) {
+	targets := GatewaysInScope(d.FeedMap.GatewaysForFeed(feedName), d.NotifiedGateways)
+	if !n.HasTargets() {
+		return targets, nil
+	}
+
+	requested := append([]string{}, n.Gateways...)
+	for _, group := range n.GatewayGroups {
+		members, err := d.CpApi.GroupMembers(group)
+		if err != nil {
+			return nil, err
+		}
+		requested = append(requested, members...)
+	}
+
+	return slices.DeleteFunc(targets, func(gw string) bool {
+		return !slices.Contains(requested, gw)
+	}), nil
+}
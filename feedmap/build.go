@@ -0,0 +1,84 @@
+package feedmap
+
+import (
+	"context"
+	"cpfeedman/cpapi"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Build runs MapScript on gateways and collects their active feeds
+// gateways which did not report in time are missing in resulting map
+func Build(ctx context.Context, cpApi *cpapi.CpApi, gwNames []string) (*FeedMap, error) {
+
+	fmt.Fprintln(os.Stdout, "[FeedMap] Mapping active feeds on each gateway. This may take a while, please wait...")
+
+	fm := NewFeedMap()
+
+	// execute mapping active feeds on each gateway
+	resp, err := cpApi.RunScript(MapScript, "map feeds", gwNames)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run map script: %w", err)
+	}
+
+	taskTargets := resp.GetTaskTargets()
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+
+	lastFinished := 0
+	result, err := cpApi.WaitForTasks(ctx, resp.GetTaskIds(), &cpapi.WaitOptions{
+		OnProgress: func(p cpapi.TaskProgress) {
+			if p.Finished != lastFinished {
+				lastFinished = p.Finished
+				fmt.Fprintf(os.Stdout, "[FeedMap] %d/%d gateways finished (%d%%)\n", p.Finished, p.Total, p.Percentage)
+			}
+		},
+	})
+	if errors.Is(err, context.DeadlineExceeded) {
+		fmt.Fprintf(os.Stderr, "[FeedMap] Timeout waiting for tasks to finish, %d unfinished.\n", len(result.Unfinished()))
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get map script results: %w", err)
+	}
+
+	// response message of each gateway of succeeded tasks
+	for _, task := range result.Succeeded() {
+		for _, gwResult := range task.GetGatewayResults() {
+			gwName := gwResult.GatewayName
+			if gwName == "" {
+				gwName = taskTargets[task.TaskID]
+			}
+			if gwResult.DecodeErr != nil {
+				fmt.Fprintf(os.Stderr, "[FeedMap] Mapping feeds on %s: %v\n", gwName, gwResult.DecodeErr)
+				continue
+			}
+			if gwName == "" || gwResult.Message == "" {
+				continue
+			}
+			fm.SetGatewayFeeds(gwName, ParseMapScriptOutput(gwResult.Message))
+		}
+	}
+
+	// gateways which failed to report keep their previous feeds
+	failedTasks := result.Failed()
+	for _, task := range failedTasks {
+		gwResults := task.GetGatewayResults()
+		if len(gwResults) == 0 {
+			fmt.Fprintf(os.Stderr, "[FeedMap] Mapping feeds on %s %s\n", taskTargets[task.TaskID], task.Status)
+		}
+		for _, gwResult := range gwResults {
+			fmt.Fprintf(os.Stderr, "[FeedMap] Mapping feeds on %s %s (%s): %s\n",
+				gwResult.GatewayName, task.Status, gwResult.StatusDescription, gwResult.Error)
+		}
+	}
+
+	if len(failedTasks) == 0 && len(result.Unfinished()) == 0 {
+		fmt.Fprintln(os.Stdout, "[FeedMap] All tasks finished successfully.")
+	} else if len(failedTasks) > 0 {
+		fmt.Fprintf(os.Stderr, "[FeedMap] %d tasks failed.\n", len(failedTasks))
+	}
+
+	return fm, nil
+}
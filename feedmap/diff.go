@@ -0,0 +1,83 @@
+package feedmap
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Change describes feeds gained and lost by a gateway between two feed maps
+type Change struct {
+	Gateway string
+	Added   []string // feeds newly enforced on gateway
+	Removed []string // feeds no longer enforced on gateway
+}
+
+func (c Change) String() string {
+	parts := make([]string, 0, 2)
+	if len(c.Added) > 0 {
+		parts = append(parts, "+"+strings.Join(c.Added, ", +"))
+	}
+	if len(c.Removed) > 0 {
+		parts = append(parts, "-"+strings.Join(c.Removed, ", -"))
+	}
+	return fmt.Sprintf("%s: %s", c.Gateway, strings.Join(parts, ", "))
+}
+
+// Diff compares gateways present in newer map with older map
+// mapped gateways missing in newer map are not reported - they did not report their feeds,
+// gateways of older map which are no longer mapped are reported to have lost all their feeds
+func Diff(older, newer *FeedMap, mapped []string) []Change {
+	gateways := newer.Gateways()
+	for _, gw := range older.Gateways() {
+		if !slices.Contains(mapped, gw) && !slices.Contains(gateways, gw) {
+			gateways = append(gateways, gw)
+		}
+	}
+	slices.Sort(gateways)
+
+	changes := make([]Change, 0)
+	for _, gw := range gateways {
+		oldFeeds := older.FeedsForGateway(gw)
+		newFeeds := newer.FeedsForGateway(gw)
+
+		change := Change{Gateway: gw}
+		for _, feed := range newFeeds {
+			if !slices.Contains(oldFeeds, feed) {
+				change.Added = append(change.Added, feed)
+			}
+		}
+		for _, feed := range oldFeeds {
+			if !slices.Contains(newFeeds, feed) {
+				change.Removed = append(change.Removed, feed)
+			}
+		}
+
+		if len(change.Added) > 0 || len(change.Removed) > 0 {
+			changes = append(changes, change)
+		}
+	}
+
+	return changes
+}
+
+// Merge takes over feeds of all gateways present in other map
+func (fm *FeedMap) Merge(other *FeedMap) {
+	for _, gw := range other.Gateways() {
+		fm.SetGatewayFeeds(gw, other.FeedsForGateway(gw))
+	}
+}
+
+// Prune removes gateways which are not mapped any more, e.g. deleted from management
+func (fm *FeedMap) Prune(mapped []string) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+
+	for gw := range fm.feedsByGateway {
+		if !slices.Contains(mapped, gw) {
+			delete(fm.feedsByGateway, gw)
+			fm.updatedAt = time.Now()
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package feedmap
+
+import (
+	"reflect"
+	"testing"
+)
+
+func feedMap(feedsByGateway map[string][]string) *FeedMap {
+	fm := NewFeedMap()
+	for gw, feeds := range feedsByGateway {
+		fm.SetGatewayFeeds(gw, feeds)
+	}
+	return fm
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name         string
+		older, newer map[string][]string
+		mapped       []string // nil - gateways of newer map
+		want         []Change
+	}{
+		{
+			name:  "no change",
+			older: map[string][]string{"gw1": {"feedME"}},
+			newer: map[string][]string{"gw1": {"feedME"}},
+			want:  []Change{},
+		},
+		{
+			name:  "added and removed",
+			older: map[string][]string{"gw1": {"feedME", "old"}},
+			newer: map[string][]string{"gw1": {"feedME", "quiccloud"}},
+			want:  []Change{{Gateway: "gw1", Added: []string{"quiccloud"}, Removed: []string{"old"}}},
+		},
+		{
+			name:  "new gateway",
+			older: map[string][]string{},
+			newer: map[string][]string{"gw2": {"feedME"}},
+			want:  []Change{{Gateway: "gw2", Added: []string{"feedME"}}},
+		},
+		{
+			name:   "mapped gateway missing in newer map is not reported",
+			older:  map[string][]string{"gw1": {"feedME"}, "gw2": {"feedME"}},
+			newer:  map[string][]string{"gw2": {}},
+			mapped: []string{"gw1", "gw2"},
+			want:   []Change{{Gateway: "gw2", Removed: []string{"feedME"}}},
+		},
+		{
+			name:   "gateway no longer mapped lost its feeds",
+			older:  map[string][]string{"gw1": {"feedME", "quiccloud"}, "gw2": {"feedME"}},
+			newer:  map[string][]string{"gw2": {"feedME"}},
+			mapped: []string{"gw2"},
+			want:   []Change{{Gateway: "gw1", Removed: []string{"feedME", "quiccloud"}}},
+		},
+		{
+			name:  "gateways in order",
+			older: map[string][]string{},
+			newer: map[string][]string{"gw2": {"b"}, "gw1": {"a"}},
+			want:  []Change{{Gateway: "gw1", Added: []string{"a"}}, {Gateway: "gw2", Added: []string{"b"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newer := feedMap(tt.newer)
+			mapped := tt.mapped
+			if mapped == nil {
+				mapped = newer.Gateways()
+			}
+			if got := Diff(feedMap(tt.older), newer, mapped); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// refresh takes over reported gateways, keeps mapped ones which did not report and drops the others
+func TestMergePrune(t *testing.T) {
+	fm := feedMap(map[string][]string{"gw1": {"feedME"}, "gw2": {"feedME"}, "gw3": {"old"}})
+	fm.Merge(feedMap(map[string][]string{"gw1": {"quiccloud"}}))
+	fm.Prune([]string{"gw1", "gw2"})
+
+	if got := fm.Gateways(); !reflect.DeepEqual(got, []string{"gw1", "gw2"}) {
+		t.Errorf("Gateways() = %q, want [gw1 gw2]", got)
+	}
+	if got := fm.GatewaysForFeed("feedME"); !reflect.DeepEqual(got, []string{"gw2"}) {
+		t.Errorf("GatewaysForFeed(feedME) = %q, want [gw2]", got)
+	}
+	if got := fm.GatewaysForFeed("old"); len(got) != 0 {
+		t.Errorf("GatewaysForFeed(old) = %q, want none", got)
+	}
+}
+
+func TestChangeString(t *testing.T) {
+	c := Change{Gateway: "gw1", Added: []string{"a", "b"}, Removed: []string{"c"}}
+	if got, want := c.String(), "gw1: +a, +b, -c"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
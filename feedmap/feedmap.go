@@ -0,0 +1,153 @@
+package feedmap
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FeedMap holds which Network Feeds are enforced on which Security Gateways
+// it is built from `dynamic_objects -efo_show` output collected on each gateway
+// and used to kick only gateways that actually use the notified feed
+
+// MapScript is executed on gateways to list active feeds
+// output format: date, hostname, then one feed name per line
+const MapScript = "(date; hostname; dynamic_objects -efo_show | grep -Po '^object name : \\K.*') | tee -a /var/log/cpfeedman.log"
+
+type FeedMap struct {
+	mu             sync.RWMutex
+	feedsByGateway map[string][]string // gateway name -> active feed names
+	feedNamesByUID map[string]string   // known feeds - feed UID -> feed name
+	updatedAt      time.Time           // last time any gateway was updated
+}
+
+func NewFeedMap() *FeedMap {
+	return &FeedMap{
+		feedsByGateway: make(map[string][]string),
+		feedNamesByUID: make(map[string]string),
+	}
+}
+
+// ParseMapScriptOutput extracts feed names from decoded MapScript output
+func ParseMapScriptOutput(output string) []string {
+	lines := strings.Split(output, "\n")
+	if len(lines) <= 2 {
+		return []string{}
+	}
+
+	// skip date and hostname lines
+	feeds := make([]string, 0, len(lines)-2)
+	seen := make(map[string]bool)
+	for _, line := range lines[2:] {
+		feed := strings.TrimSpace(line)
+		if feed == "" || seen[feed] {
+			continue
+		}
+		seen[feed] = true
+		feeds = append(feeds, feed)
+	}
+	sort.Strings(feeds)
+
+	return feeds
+}
+
+// SetGatewayFeeds replaces the list of feeds active on a gateway
+func (fm *FeedMap) SetGatewayFeeds(gateway string, feeds []string) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+
+	sorted := append([]string{}, feeds...)
+	sort.Strings(sorted)
+	fm.feedsByGateway[gateway] = sorted
+	fm.updatedAt = time.Now()
+}
+
+// SetFeedNames replaces known feeds (feed UID -> feed name)
+func (fm *FeedMap) SetFeedNames(feedNamesByUID map[string]string) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+
+	fm.feedNamesByUID = maps.Clone(feedNamesByUID)
+}
+
+// FeedName returns name of known feed given by name or UID, empty if feed is unknown
+func (fm *FeedMap) FeedName(name, uid string) string {
+	fm.mu.RLock()
+	defer fm.mu.RUnlock()
+
+	for feedUID, feedName := range fm.feedNamesByUID {
+		if (name != "" && name == feedName) || (name == "" && uid == feedUID) {
+			return feedName
+		}
+	}
+	return ""
+}
+
+// FeedNames returns sorted names of known feeds
+func (fm *FeedMap) FeedNames() []string {
+	fm.mu.RLock()
+	defer fm.mu.RUnlock()
+
+	return slices.Sorted(maps.Values(fm.feedNamesByUID))
+}
+
+// GatewaysForFeed returns sorted names of gateways enforcing the feed
+func (fm *FeedMap) GatewaysForFeed(feed string) []string {
+	fm.mu.RLock()
+	defer fm.mu.RUnlock()
+
+	gateways := make([]string, 0)
+	for gw, feeds := range fm.feedsByGateway {
+		for _, f := range feeds {
+			if f == feed {
+				gateways = append(gateways, gw)
+				break
+			}
+		}
+	}
+	sort.Strings(gateways)
+
+	return gateways
+}
+
+// FeedsForGateway returns feeds active on the gateway
+func (fm *FeedMap) FeedsForGateway(gateway string) []string {
+	fm.mu.RLock()
+	defer fm.mu.RUnlock()
+
+	return append([]string{}, fm.feedsByGateway[gateway]...)
+}
+
+// Gateways returns sorted names of all mapped gateways
+func (fm *FeedMap) Gateways() []string {
+	fm.mu.RLock()
+	defer fm.mu.RUnlock()
+
+	gateways := make([]string, 0, len(fm.feedsByGateway))
+	for gw := range fm.feedsByGateway {
+		gateways = append(gateways, gw)
+	}
+	sort.Strings(gateways)
+
+	return gateways
+}
+
+func (fm *FeedMap) UpdatedAt() time.Time {
+	fm.mu.RLock()
+	defer fm.mu.RUnlock()
+
+	return fm.updatedAt
+}
+
+// String renders the map as one "gateway: feed1, feed2" line per gateway
+func (fm *FeedMap) String() string {
+	var sb strings.Builder
+	for _, gw := range fm.Gateways() {
+		fmt.Fprintf(&sb, "%s: %s\n", gw, strings.Join(fm.FeedsForGateway(gw), ", "))
+	}
+	return sb.String()
+}
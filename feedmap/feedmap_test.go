@@ -0,0 +1,60 @@
+package feedmap
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseMapScriptOutput(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   []string
+	}{
+		{"empty", "", []string{}},
+		{"date and hostname only", "Mon Oct 12 10:00:00 UTC 2026\ngw1\n", []string{}},
+		{"feeds sorted", "Mon Oct 12 10:00:00 UTC 2026\ngw1\nquiccloud\nfeedME\n", []string{"feedME", "quiccloud"}},
+		{"blank lines and spaces", "date\ngw1\n\n  feedME \n\t\n", []string{"feedME"}},
+		{"duplicates", "date\ngw1\nfeedME\nfeedME\n", []string{"feedME"}},
+		{"CRLF", "date\r\ngw1\r\nfeedME\r\n", []string{"feedME"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseMapScriptOutput(tt.output); !slices.Equal(got, tt.want) {
+				t.Errorf("ParseMapScriptOutput(%q) = %q, want %q", tt.output, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFeedMapLookups(t *testing.T) {
+	fm := NewFeedMap()
+	fm.SetGatewayFeeds("gw2", []string{"quiccloud", "feedME"})
+	fm.SetGatewayFeeds("gw1", []string{"feedME"})
+	fm.SetFeedNames(map[string]string{"uid-1": "feedME", "uid-2": "quiccloud"})
+
+	if got := fm.GatewaysForFeed("feedME"); !slices.Equal(got, []string{"gw1", "gw2"}) {
+		t.Errorf("GatewaysForFeed(feedME) = %q, want [gw1 gw2]", got)
+	}
+	if got := fm.GatewaysForFeed("unknown"); len(got) != 0 {
+		t.Errorf("GatewaysForFeed(unknown) = %q, want none", got)
+	}
+	if got := fm.FeedsForGateway("gw2"); !slices.Equal(got, []string{"feedME", "quiccloud"}) {
+		t.Errorf("FeedsForGateway(gw2) = %q, want [feedME quiccloud]", got)
+	}
+
+	feedNames := []struct {
+		name, uid string
+		want      string
+	}{
+		{"feedME", "", "feedME"},
+		{"", "uid-2", "quiccloud"},
+		{"unknown", "uid-1", ""},
+		{"", "uid-3", ""},
+	}
+	for _, tt := range feedNames {
+		if got := fm.FeedName(tt.name, tt.uid); got != tt.want {
+			t.Errorf("FeedName(%q, %q) = %q, want %q", tt.name, tt.uid, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package feedmap
+
+import (
+	"context"
+	"cpfeedman/cpapi"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Refresher keeps FeedMap up to date in background
+// mapping is repeated every Interval (0 disables) and after each finished policy installation
+// (detected by polling management tasks every PolicyPollInterval, 0 disables)
+
+type Refresher struct {
+	FeedMap            *FeedMap
+	Interval           time.Duration
+	PolicyPollInterval time.Duration
+	OnChange           func(changes []Change) // optional callback with gateways which gained or lost feeds
+
+	cpApi    *cpapi.CpApi
+	gateways func() ([]string, error) // gateways to be mapped
+	trigger  chan struct{}
+
+	mu          sync.Mutex
+	lastRefresh time.Time
+	lastChanges []Change
+}
+
+func NewRefresher(cpApi *cpapi.CpApi, fm *FeedMap, gateways func() ([]string, error), interval time.Duration) *Refresher {
+	return &Refresher{
+		FeedMap:            fm,
+		Interval:           interval,
+		PolicyPollInterval: time.Minute,
+		cpApi:              cpApi,
+		gateways:           gateways,
+		trigger:            make(chan struct{}, 1),
+		lastRefresh:        fm.UpdatedAt(),
+	}
+}
+
+// Start runs refresh loop until ctx is cancelled
+func (r *Refresher) Start(ctx context.Context) {
+	go r.refreshLoop(ctx)
+	if r.PolicyPollInterval > 0 {
+		go r.policyLoop(ctx)
+	}
+}
+
+// Trigger requests refresh as soon as possible, multiple requests are merged
+func (r *Refresher) Trigger() {
+	select {
+	case r.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Refresh reloads known feeds, maps feeds on gateways now and merges result into FeedMap
+// mapped gateways which failed to report keep their previous feeds, gateways no longer mapped are removed
+func (r *Refresher) Refresh(ctx context.Context) ([]Change, error) {
+	gwNames, err := r.gateways()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get gateways: %w", err)
+	}
+
+	// feeds created since last refresh, e.g. by policy installation
+	feedNamesByUID, err := r.cpApi.FeedNamesByUID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get feeds: %w", err)
+	}
+	oldNames := r.FeedMap.FeedNames()
+	r.FeedMap.SetFeedNames(feedNamesByUID)
+	if newNames := r.FeedMap.FeedNames(); !slices.Equal(oldNames, newNames) {
+		fmt.Fprintf(os.Stdout, "[FeedMap] Known feeds changed: %s\n", strings.Join(newNames, ", "))
+	}
+
+	newMap, err := Build(ctx, r.cpApi, gwNames)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := Diff(r.FeedMap, newMap, gwNames)
+	r.FeedMap.Merge(newMap)
+	r.FeedMap.Prune(gwNames)
+
+	r.mu.Lock()
+	r.lastRefresh = time.Now()
+	r.lastChanges = changes
+	r.mu.Unlock()
+
+	return changes, nil
+}
+
+// LastChanges returns changes detected by the most recent refresh
+func (r *Refresher) LastChanges() []Change {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]Change{}, r.lastChanges...)
+}
+
+func (r *Refresher) LastRefresh() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.lastRefresh
+}
+
+func (r *Refresher) refreshLoop(ctx context.Context) {
+	// zero interval - refresh on trigger only
+	var tick <-chan time.Time
+	if r.Interval > 0 {
+		ticker := time.NewTicker(r.Interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+		case <-r.trigger:
+		}
+
+		changes, err := r.Refresh(ctx)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "[FeedMap] Refresh failed:", err)
+			continue
+		}
+
+		if len(changes) == 0 {
+			fmt.Fprintln(os.Stdout, "[FeedMap] Refresh finished, no changes.")
+			continue
+		}
+		for _, change := range changes {
+			fmt.Fprintf(os.Stdout, "[FeedMap] Changed %s\n", change)
+		}
+		if r.OnChange != nil {
+			r.OnChange(changes)
+		}
+	}
+}
+
+// policyPendingLimit is how long an unfinished policy installation is followed
+const policyPendingLimit = 12 * time.Hour
+
+// policyLoop triggers refresh when policy installation finishes
+// installations are discovered by show-tasks of recent poll window and followed by task ID
+// until they finish, so installs running longer than the window are not missed
+func (r *Refresher) policyLoop(ctx context.Context) {
+	ticker := time.NewTicker(r.PolicyPollInterval)
+	defer ticker.Stop()
+
+	since := time.Now()
+	seen := make(map[string]bool)         // finished installations reported within current window
+	pending := make(map[string]time.Time) // task ID -> first seen, installations in progress
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		tasks, err := r.cpApi.ShowRecentTasks(since.Add(-r.PolicyPollInterval))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "[FeedMap] Failed to check policy installations:", err)
+			continue
+		}
+		since = time.Now()
+
+		// polled windows overlap, remember tasks of current window only
+		installed := false
+		current := make(map[string]bool)
+		inWindow := make(map[string]bool)
+		for _, task := range tasks.GetPolicyInstallTasks() {
+			inWindow[task.TaskID] = true
+			if !task.Status.IsFinal() {
+				if _, ok := pending[task.TaskID]; !ok {
+					pending[task.TaskID] = time.Now()
+				}
+				continue
+			}
+			delete(pending, task.TaskID)
+			current[task.TaskID] = true
+			if seen[task.TaskID] {
+				continue
+			}
+			installed = true
+			fmt.Fprintf(os.Stdout, "[FeedMap] Policy installation finished: %s (%s)\n", task.TaskName, task.Status)
+		}
+		seen = current
+
+		// installations which left the window while in progress
+		var followed []string
+		for taskID, firstSeen := range pending {
+			if inWindow[taskID] {
+				continue
+			}
+			if time.Since(firstSeen) > policyPendingLimit {
+				fmt.Fprintf(os.Stderr, "[FeedMap] Policy installation task %s not finished after %s, not followed anymore\n", taskID, policyPendingLimit)
+				delete(pending, taskID)
+				continue
+			}
+			followed = append(followed, taskID)
+		}
+		if len(followed) > 0 {
+			followedTasks, err := r.cpApi.ShowTasks(followed)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "[FeedMap] Failed to check policy installations in progress:", err)
+			}
+			for _, task := range followedTasks.GetFinishedPolicyInstallTasks() {
+				delete(pending, task.TaskID)
+				installed = true
+				fmt.Fprintf(os.Stdout, "[FeedMap] Policy installation finished: %s (%s)\n", task.TaskName, task.Status)
+			}
+		}
+
+		if installed {
+			r.Trigger()
+		}
+	}
+}
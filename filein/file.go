@@ -0,0 +1,265 @@
+package filein
+
+import (
+	"context"
+	"cpfeedman/input"
+	"cpfeedman/notification"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// FileIn represents filesystem watch input - notifies feed when content of watched file changes, implements input.Source
+// path rule "<path pattern>=<feed>" - pattern uses filepath.Match syntax in the file name only,
+// feed "*" stands for file name without extension, e.g.
+//   /srv/feeds/*.txt=*               /srv/feeds/feedME.txt -> feedME
+//   /srv/feeds/quic/latest.csv=quiccloud
+//
+// directories of patterns are watched, file is hashed (SHA-256) once no event arrived for Settle,
+// so file being written is not hashed half way and touch-only changes (same content) are ignored
+// hashes of existing files are taken on start, they do not notify
+// Retry is handled again after RetryDelay up to MaxRetries times, then the change is forgotten
+// and next event on the file notifies again
+
+type PathRule struct {
+	Pattern string
+	Feed    string
+}
+
+type FileIn struct {
+	PathRules []PathRule
+	Settle    time.Duration
+
+	input.Retrier
+	input.Stopper
+
+	mu     sync.Mutex        // guards hashes
+	hashes map[string]string // path -> content hash
+}
+
+func NewFileIn(pathRules []PathRule) *FileIn {
+	return &FileIn{
+		PathRules: pathRules,
+		Settle:    2 * time.Second,
+		Retrier:   input.NewRetrier(),
+	}
+}
+
+// ParsePathRules parses "<path pattern>=<feed>" rule specifications
+func ParsePathRules(specs []string) ([]PathRule, error) {
+	rules := make([]PathRule, 0, len(specs))
+	for _, spec := range specs {
+		pattern, feed, ok := strings.Cut(spec, "=")
+		pattern, feed = strings.TrimSpace(pattern), strings.TrimSpace(feed)
+		if !ok || pattern == "" || feed == "" {
+			return nil, fmt.Errorf("invalid path rule %q, expecting <path pattern>=<feed>", spec)
+		}
+		pattern = filepath.Clean(pattern)
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern in path rule %q: %w", spec, err)
+		}
+		if strings.ContainsAny(filepath.Dir(pattern), "*?[") {
+			return nil, fmt.Errorf("invalid path rule %q, wildcards are allowed in file name only", spec)
+		}
+		rules = append(rules, PathRule{Pattern: pattern, Feed: feed})
+	}
+	return rules, nil
+}
+
+// Match returns feed name for file, empty if rule does not match
+func (r PathRule) Match(path string) string {
+	if ok, _ := filepath.Match(r.Pattern, path); !ok {
+		return ""
+	}
+	if r.Feed == "*" {
+		name := filepath.Base(path)
+		return strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	return r.Feed
+}
+
+// Name is Files, all rules share one watcher
+func (f *FileIn) Name() string {
+	return "Files"
+}
+
+// feedFor returns feed of first matching rule
+func (f *FileIn) feedFor(path string) string {
+	for _, rule := range f.PathRules {
+		if feed := rule.Match(path); feed != "" {
+			return feed
+		}
+	}
+	return ""
+}
+
+func (f *FileIn) Listen(handler input.Handler) error {
+	ctx, cancel := f.Listening()
+	defer cancel()
+	f.mu.Lock()
+	f.hashes = make(map[string]string)
+	f.mu.Unlock()
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	for _, rule := range f.PathRules {
+		dir := filepath.Dir(rule.Pattern)
+		if err := watcher.Add(dir); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", dir, err)
+		}
+
+		matches, _ := filepath.Glob(rule.Pattern)
+		for _, path := range matches {
+			if hash, err := hashFile(path); err == nil {
+				f.hashes[path] = hash
+			}
+		}
+	}
+	log.Printf("[FILEIN] Watching %d files in %v", len(f.hashes), watcher.WatchList())
+
+	var inFlight sync.WaitGroup
+	timers := make(map[string]*time.Timer) // path -> pending check
+	settled := make(chan string)
+
+	for {
+		select {
+		case <-ctx.Done():
+			for _, timer := range timers {
+				timer.Stop()
+			}
+			// let handlers finish before returning
+			inFlight.Wait()
+			return nil
+
+		case err := <-watcher.Errors:
+			log.Printf("[FILEIN] watch error: %v", err)
+
+		case event := <-watcher.Events:
+			path := filepath.Clean(event.Name)
+			if f.feedFor(path) == "" {
+				continue
+			}
+			if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+				// file recreated later notifies even with the same content
+				f.mu.Lock()
+				delete(f.hashes, path)
+				f.mu.Unlock()
+				continue
+			}
+			if timer, ok := timers[path]; ok {
+				timer.Reset(f.Settle)
+				continue
+			}
+			timers[path] = time.AfterFunc(f.Settle, func() {
+				select {
+				case settled <- path:
+				case <-ctx.Done():
+				}
+			})
+
+		case path := <-settled:
+			delete(timers, path)
+			inFlight.Add(1)
+			go func() {
+				defer inFlight.Done()
+				f.check(ctx, handler, path)
+			}()
+		}
+	}
+}
+
+// check notifies feed of file if its content changed since last check
+func (f *FileIn) check(ctx context.Context, handler input.Handler, path string) {
+	hash, err := hashFile(path)
+	if err != nil {
+		log.Printf("[FILEIN] failed to hash %s: %v", path, err)
+		return
+	}
+
+	f.mu.Lock()
+	previous, known := f.hashes[path]
+	if known && previous == hash {
+		f.mu.Unlock()
+		log.Printf("[FILEIN] Content of %s not changed", path)
+		return
+	}
+	f.hashes[path] = hash
+	f.mu.Unlock()
+
+	if !f.notify(ctx, handler, path, hash) {
+		// forget the change, so next event on the file notifies again
+		f.mu.Lock()
+		if f.hashes[path] == hash {
+			if known {
+				f.hashes[path] = previous
+			} else {
+				delete(f.hashes, path)
+			}
+		}
+		f.mu.Unlock()
+	}
+}
+
+// notify returns false when retries were exhausted or stopped before outcome is known
+func (f *FileIn) notify(ctx context.Context, handler input.Handler, path, hash string) bool {
+	body, err := json.Marshal(&notification.Notification{
+		Version:       notification.CurrentVersion,
+		Feed:          f.feedFor(path),
+		Requester:     "cpfeedman-file-watch",
+		CorrelationID: hash[:12],
+		Reason:        "content of " + path + " changed",
+	})
+	if err != nil {
+		log.Printf("[FILEIN] failed to encode notification for %s: %v", path, err)
+		return false
+	}
+	log.Printf("[FILEIN] Content of %s changed (sha256 %s)", path, hash[:12])
+
+	outcome, ok := f.HandleRetrying(ctx, handler, &input.Message{
+		Source:     f.Name(),
+		ID:         path + "@" + hash[:12],
+		Body:       string(body),
+		Attributes: map[string]string{"file-path": path, "file-sha256": hash},
+	})
+	if ok && outcome == input.Retry {
+		log.Printf("[FILEIN] Giving up change of %s after %d retries", path, f.MaxRetries)
+	}
+	return ok && outcome != input.Retry
+}
+
+// hashFile returns hex SHA-256 of regular file content
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("not a regular file")
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,27 @@
+package input
+
+import "fmt"
+
+// Outcome of notification processing reported back to input
+// inputs acknowledge message on Ack, redeliver it later on Retry
+// and drop it or move it aside (e.g. dead-letter queue) on Reject
+
+type Outcome int
+
+const (
+	Ack    Outcome = iota // processed, delete message
+	Retry                 // temporary failure, redeliver message later
+	Reject                // message cannot be processed, move to dead-letter queue
+)
+
+func (o Outcome) String() string {
+	switch o {
+	case Ack:
+		return "ack"
+	case Retry:
+		return "retry"
+	case Reject:
+		return "reject"
+	}
+	return fmt.Sprintf("outcome(%d)", int(o))
+}
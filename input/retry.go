@@ -0,0 +1,42 @@
+package input
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Retrier handles message again while handler returns Retry, used by sources which cannot
+// redeliver message later themselves
+// message is handled again after RetryDelay up to MaxRetries times
+
+type Retrier struct {
+	RetryDelay time.Duration
+	MaxRetries int
+}
+
+func NewRetrier() Retrier {
+	return Retrier{
+		RetryDelay: 30 * time.Second,
+		MaxRetries: 5,
+	}
+}
+
+// HandleRetrying passes message to handler until it returns other outcome than Retry or retries
+// are exhausted (outcome is Retry then), ok is false when ctx was cancelled before outcome is known
+func (r Retrier) HandleRetrying(ctx context.Context, handler Handler, msg *Message) (outcome Outcome, ok bool) {
+	for attempt := 0; ; attempt++ {
+		outcome = handler(msg)
+		if outcome != Retry || attempt >= r.MaxRetries {
+			return outcome, true
+		}
+
+		fmt.Fprintf(os.Stdout, "[Input] Message %s from %s will be retried in %s\n", msg.ID, msg.Source, r.RetryDelay)
+		select {
+		case <-ctx.Done():
+			return outcome, false
+		case <-time.After(r.RetryDelay):
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package input
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Source is a transport delivering notification messages (SQS queue, webhook, ...)
+// Listen blocks and passes each received message to handler, message is then
+// acknowledged, redelivered or rejected by the source according to returned Outcome
+// Stop makes Listen return once messages being handled are settled
+
+type Source interface {
+	Name() string
+	Listen(handler Handler) error
+	Stop()
+}
+
+// Message received by a source
+type Message struct {
+	Source     string            // name of source which received the message
+	ID         string            // transport message ID, if any
+	Body       string            // notification
+	Attributes map[string]string // message attributes or headers
+}
+
+// Handler processes message
+type Handler func(msg *Message) Outcome
+
+// Submitter starts processing of message and returns function waiting for its outcome,
+// source keeping messages in order submits them in order and may wait for outcomes concurrently
+type Submitter func(msg *Message) (wait func() Outcome)
+
+// Submitter returns submitter processing message by handler before wait function is returned
+func (h Handler) Submitter() Submitter {
+	return func(msg *Message) func() Outcome {
+		outcome := h(msg)
+		return func() Outcome { return outcome }
+	}
+}
+
+// Group runs several sources at once, feeding a single handler
+type Group struct {
+	sources []Source
+}
+
+func NewGroup(sources ...Source) *Group {
+	return &Group{sources: sources}
+}
+
+func (g *Group) Add(source Source) {
+	g.sources = append(g.sources, source)
+}
+
+func (g *Group) Sources() []Source {
+	return g.sources
+}
+
+// Listen runs all sources until they stop, failure of one source stops the others
+func (g *Group) Listen(handler Handler) error {
+	if len(g.sources) == 0 {
+		return fmt.Errorf("no input source configured")
+	}
+
+	var wg sync.WaitGroup
+	var stopOnce sync.Once
+	errs := make(chan error, len(g.sources))
+	for _, source := range g.sources {
+		wg.Add(1)
+		go func(source Source) {
+			defer wg.Done()
+
+			fmt.Fprintf(os.Stdout, "[Input] Starting %s\n", source.Name())
+			if err := source.Listen(handler); err != nil {
+				errs <- fmt.Errorf("%s: %w", source.Name(), err)
+				stopOnce.Do(g.Stop)
+				return
+			}
+			fmt.Fprintf(os.Stdout, "[Input] Stopped %s\n", source.Name())
+		}(source)
+	}
+	wg.Wait()
+	close(errs)
+
+	return <-errs
+}
+
+// Stop stops all sources
+func (g *Group) Stop() {
+	for _, source := range g.sources {
+		source.Stop()
+	}
+}
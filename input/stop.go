@@ -0,0 +1,40 @@
+package input
+
+import (
+	"context"
+	"sync"
+)
+
+// Stopper implements Stop of sources which listen until their context is cancelled
+// source embeds Stopper and takes context of Listen from Listening
+
+type Stopper struct {
+	mu      sync.Mutex
+	cancel  context.CancelFunc
+	stopped bool
+}
+
+// Listening returns context cancelled by Stop, already cancelled if Stop was called before
+func (s *Stopper) Listening() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cancel = cancel
+	if s.stopped {
+		cancel()
+	}
+	return ctx, cancel
+}
+
+// Stop cancels context of Listen
+func (s *Stopper) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stopped = true
+	if s.cancel != nil {
+		s.cancel()
+	}
+}
@@ -0,0 +1,152 @@
+package kafkain
+
+import (
+	"context"
+	"cpfeedman/input"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// KafkaIn represents Kafka input - joins consumer group and consumes topics, implements input.Source
+// offset of a message is committed only after kick outcome is known (Ack or Reject),
+// Retry is handled again after RetryDelay up to MaxRetries times, then the message is skipped
+// messages of a partition are handled in order
+//
+// notification is taken from topic rule, message value or message key (in this order):
+// topic rule "<topic>=<feed>" notifies the feed for every message on the topic,
+// otherwise message value is any supported notification format (headers are message attributes),
+// empty value falls back to message key as feed name
+
+// expecting broker addresses, e.g. kafka1:9092,kafka2:9092
+
+type KafkaIn struct {
+	Brokers    []string
+	GroupID    string
+	Topics     []string
+	TopicFeeds map[string]string // topic -> feed, optional
+
+	input.Retrier
+	input.Stopper
+}
+
+func NewKafkaIn(brokers []string, groupID string, topics []string) *KafkaIn {
+	return &KafkaIn{
+		Brokers:    brokers,
+		GroupID:    groupID,
+		Topics:     topics,
+		TopicFeeds: map[string]string{},
+		Retrier:    input.NewRetrier(),
+	}
+}
+
+// ParseTopics parses "<topic>" or "<topic>=<feed>" specifications into topics and topic feeds
+func ParseTopics(specs []string) ([]string, map[string]string, error) {
+	topics := make([]string, 0, len(specs))
+	topicFeeds := make(map[string]string)
+	for _, spec := range specs {
+		topic, feed, hasFeed := strings.Cut(spec, "=")
+		topic, feed = strings.TrimSpace(topic), strings.TrimSpace(feed)
+		if topic == "" || (hasFeed && feed == "") {
+			return nil, nil, fmt.Errorf("invalid Kafka topic %q, expecting <topic> or <topic>=<feed>", spec)
+		}
+		topics = append(topics, topic)
+		if hasFeed {
+			topicFeeds[topic] = feed
+		}
+	}
+	return topics, topicFeeds, nil
+}
+
+// Name is Kafka with consumer group, e.g. Kafka:cpfeedman
+func (k *KafkaIn) Name() string {
+	return "Kafka:" + k.GroupID
+}
+
+func (k *KafkaIn) Listen(handler input.Handler) error {
+	ctx, cancel := k.Listening()
+	defer cancel()
+
+	config := kafka.ReaderConfig{
+		Brokers:        k.Brokers,
+		GroupID:        k.GroupID,
+		GroupTopics:    k.Topics,
+		CommitInterval: 0, // synchronous commits
+		StartOffset:    kafka.LastOffset,
+	}
+	// NewReader panics on invalid configuration
+	if err := config.Validate(); err != nil {
+		return fmt.Errorf("invalid Kafka reader configuration: %w", err)
+	}
+	reader := kafka.NewReader(config)
+	defer reader.Close()
+
+	log.Printf("[KAFKAIN] Consuming topics %v in group %s", k.Topics, k.GroupID)
+
+	for {
+		msg, err := reader.FetchMessage(ctx)
+		if ctx.Err() != nil {
+			return nil // stopped
+		}
+		if err != nil {
+			if errors.Is(err, kafka.ErrGroupClosed) {
+				return err
+			}
+			log.Printf("[KAFKAIN] error fetching message: %v", err)
+			time.Sleep(5 * time.Second)
+			continue
+		}
+
+		if !k.handle(ctx, handler, &msg) {
+			return nil // stopped while retrying, message is not committed
+		}
+
+		// received messages are committed even when consuming was stopped
+		if err := reader.CommitMessages(context.Background(), msg); err != nil {
+			log.Printf("[KAFKAIN] failed to commit offset %d of %s/%d: %v", msg.Offset, msg.Topic, msg.Partition, err)
+		}
+	}
+}
+
+// handle returns false when stopped before outcome is known
+func (k *KafkaIn) handle(ctx context.Context, handler input.Handler, msg *kafka.Message) bool {
+	log.Printf("[KAFKAIN] Received message %s/%d@%d: %s", msg.Topic, msg.Partition, msg.Offset, msg.Value)
+
+	body := string(msg.Value)
+	if feed, ok := k.TopicFeeds[msg.Topic]; ok {
+		body = feed
+	} else if strings.TrimSpace(body) == "" {
+		body = string(msg.Key)
+	}
+
+	outcome, ok := k.HandleRetrying(ctx, handler, &input.Message{
+		Source:     k.Name(),
+		ID:         fmt.Sprintf("%s/%d@%d", msg.Topic, msg.Partition, msg.Offset),
+		Body:       body,
+		Attributes: MessageAttributes(msg),
+	})
+	if ok && outcome == input.Retry {
+		log.Printf("[KAFKAIN] Skipping message %s/%d@%d after %d retries", msg.Topic, msg.Partition, msg.Offset, k.MaxRetries)
+	}
+	return ok
+}
+
+// MessageAttributes returns headers, topic and key of message
+// CloudEvents Kafka binding headers (ce_type) are renamed to ce-type
+func MessageAttributes(msg *kafka.Message) map[string]string {
+	attributes := make(map[string]string, len(msg.Headers)+2)
+	for _, header := range msg.Headers {
+		name := header.Key
+		if rest, ok := strings.CutPrefix(name, "ce_"); ok {
+			name = "ce-" + rest
+		}
+		attributes[name] = string(header.Value)
+	}
+	attributes["kafka-topic"] = msg.Topic
+	attributes["kafka-key"] = string(msg.Key)
+	return attributes
+}
@@ -0,0 +1,195 @@
+package kicker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Coalescer merges notifications for the same feed into a single kick
+// first notification opens per-feed window, notifications arriving within the window
+// (or while previous kick of the feed is still running) are merged into one kick
+// targets of merged notifications are united, so each gateway gets exactly one run-script
+// once context of the coalescer is cancelled (shutdown), kicks not started yet are refused with ErrShutdown
+
+// ErrShutdown refuses kick which was not started before shutdown
+var ErrShutdown = errors.New("shutting down, kick not started")
+
+// KickFunc triggers feed update on targets, report is nil if kick could not be started
+// kick is not started once ctx is cancelled
+type KickFunc func(ctx context.Context, feed string, targets []string) (*Report, error)
+
+// Result of a coalesced kick, delivered to every merged notification
+type Result struct {
+	Feed    string
+	Targets []string
+	Merged  int // number of notifications served by this kick
+	Report  *Report
+	Err     error
+}
+
+// Stats counts notifications and kicks since start
+type Stats struct {
+	Received uint64 // notifications submitted
+	Kicks    uint64 // kicks executed
+	Merged   uint64 // notifications served by kick of another notification
+}
+
+func (s Stats) String() string {
+	return fmt.Sprintf("received=%d kicks=%d merged=%d", s.Received, s.Kicks, s.Merged)
+}
+
+type Coalescer struct {
+	Window time.Duration // debounce window, 0 kicks immediately
+
+	ctx  context.Context
+	kick KickFunc
+
+	mu      sync.Mutex
+	pending map[string]*pendingKick // feed -> kick waiting for window or running kick
+	running map[string]bool         // feed -> kick in progress
+	stats   Stats
+}
+
+type pendingKick struct {
+	targets map[string]bool
+	waiters []chan Result
+	ready   bool // window elapsed
+}
+
+func NewCoalescer(ctx context.Context, window time.Duration, kick KickFunc) *Coalescer {
+	c := &Coalescer{
+		Window:  window,
+		ctx:     ctx,
+		kick:    kick,
+		pending: make(map[string]*pendingKick),
+		running: make(map[string]bool),
+	}
+	context.AfterFunc(ctx, c.refusePending)
+	return c
+}
+
+// Submit schedules kick of feed on targets, returned channel receives result once kick finishes
+func (c *Coalescer) Submit(feed string, targets []string) <-chan Result {
+	return c.submit(feed, targets, false)
+}
+
+// SubmitUrgent schedules kick without waiting for debounce window
+// notifications already waiting in the window are served by the same kick
+func (c *Coalescer) SubmitUrgent(feed string, targets []string) <-chan Result {
+	return c.submit(feed, targets, true)
+}
+
+func (c *Coalescer) submit(feed string, targets []string, urgent bool) <-chan Result {
+	resCh := make(chan Result, 1)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stats.Received++
+
+	if c.ctx.Err() != nil {
+		resCh <- Result{Feed: feed, Targets: targets, Merged: 1, Err: ErrShutdown}
+		return resCh
+	}
+
+	pk, ok := c.pending[feed]
+	if !ok {
+		pk = &pendingKick{targets: make(map[string]bool)}
+		c.pending[feed] = pk
+		if c.Window > 0 && !urgent {
+			time.AfterFunc(c.Window, func() { c.windowElapsed(feed, pk) })
+		} else {
+			pk.ready = true
+		}
+	} else {
+		c.stats.Merged++
+		if urgent {
+			pk.ready = true
+		}
+	}
+
+	for _, gw := range targets {
+		pk.targets[gw] = true
+	}
+	pk.waiters = append(pk.waiters, resCh)
+
+	if pk.ready && !c.running[feed] {
+		c.startLocked(feed)
+	}
+
+	return resCh
+}
+
+func (c *Coalescer) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.stats
+}
+
+// refusePending refuses kicks waiting for window or running kick of their feed
+func (c *Coalescer) refusePending() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for feed, pk := range c.pending {
+		fmt.Fprintf(os.Stdout, "[Kicker] Feed '%s': kick of %d notifications not started before shutdown\n", feed, len(pk.waiters))
+		res := Result{Feed: feed, Merged: len(pk.waiters), Err: ErrShutdown}
+		for _, w := range pk.waiters {
+			w <- res
+		}
+		delete(c.pending, feed)
+	}
+}
+
+func (c *Coalescer) windowElapsed(feed string, pk *pendingKick) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	pk.ready = true
+	if c.pending[feed] == pk && !c.running[feed] {
+		c.startLocked(feed)
+	}
+}
+
+// startLocked runs pending kick of feed, expects mu to be held
+func (c *Coalescer) startLocked(feed string) {
+	pk := c.pending[feed]
+	delete(c.pending, feed)
+	c.running[feed] = true
+	c.stats.Kicks++
+
+	targets := make([]string, 0, len(pk.targets))
+	for gw := range pk.targets {
+		targets = append(targets, gw)
+	}
+	sort.Strings(targets)
+
+	if len(pk.waiters) > 1 {
+		fmt.Fprintf(os.Stdout, "[Kicker] Feed '%s': merged %d notifications into one kick\n", feed, len(pk.waiters))
+	}
+
+	go func() {
+		report, err := c.kick(c.ctx, feed, targets)
+
+		res := Result{Feed: feed, Targets: targets, Merged: len(pk.waiters), Report: report, Err: err}
+		for _, w := range pk.waiters {
+			w <- res
+		}
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		delete(c.running, feed)
+		fmt.Fprintf(os.Stdout, "[Kicker] Feed '%s' kick finished. Totals: %s\n", feed, c.stats)
+		// notifications received while kick was running
+		if next, ok := c.pending[feed]; ok && next.ready {
+			c.startLocked(feed)
+		}
+	}()
+}
@@ -0,0 +1,151 @@
+package kicker
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder is KickFunc recording kicks, each kick takes delay
+type recorder struct {
+	delay time.Duration
+
+	mu    sync.Mutex
+	kicks [][]string // targets of each kick
+}
+
+func (r *recorder) kick(ctx context.Context, feed string, targets []string) (*Report, error) {
+	r.mu.Lock()
+	r.kicks = append(r.kicks, targets)
+	r.mu.Unlock()
+
+	time.Sleep(r.delay)
+	return &Report{Feed: feed}, nil
+}
+
+func (r *recorder) Kicks() [][]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return slices.Clone(r.kicks)
+}
+
+func receive(t *testing.T, resCh <-chan Result) Result {
+	t.Helper()
+	select {
+	case res := <-resCh:
+		return res
+	case <-time.After(time.Second):
+		t.Fatal("no result within a second")
+		return Result{}
+	}
+}
+
+func TestCoalescerMergesWithinWindow(t *testing.T) {
+	r := &recorder{}
+	c := NewCoalescer(context.Background(), 50*time.Millisecond, r.kick)
+
+	resChs := []<-chan Result{
+		c.Submit("feedME", []string{"gw1"}),
+		c.Submit("feedME", []string{"gw2", "gw1"}),
+		c.Submit("feedME", []string{"gw3"}),
+	}
+	for _, resCh := range resChs {
+		res := receive(t, resCh)
+		if res.Err != nil || res.Merged != 3 {
+			t.Errorf("result err=%v merged=%d, want no error and merged=3", res.Err, res.Merged)
+		}
+	}
+
+	if kicks := r.Kicks(); len(kicks) != 1 || !slices.Equal(kicks[0], []string{"gw1", "gw2", "gw3"}) {
+		t.Errorf("kicks %v, want single kick of [gw1 gw2 gw3]", kicks)
+	}
+	if stats := c.Stats(); stats != (Stats{Received: 3, Kicks: 1, Merged: 2}) {
+		t.Errorf("stats %s, want received=3 kicks=1 merged=2", stats)
+	}
+}
+
+func TestCoalescerFeedsKickedSeparately(t *testing.T) {
+	r := &recorder{}
+	c := NewCoalescer(context.Background(), 0, r.kick)
+
+	receive(t, c.Submit("feedME", []string{"gw1"}))
+	receive(t, c.Submit("quiccloud", []string{"gw1"}))
+
+	if kicks := r.Kicks(); len(kicks) != 2 {
+		t.Errorf("%d kicks, want 2", len(kicks))
+	}
+}
+
+func TestCoalescerUrgentSkipsWindow(t *testing.T) {
+	r := &recorder{}
+	c := NewCoalescer(context.Background(), time.Hour, r.kick)
+
+	waiting := c.Submit("feedME", []string{"gw1"})
+	urgent := c.SubmitUrgent("feedME", []string{"gw2"})
+
+	for _, resCh := range []<-chan Result{waiting, urgent} {
+		if res := receive(t, resCh); res.Merged != 2 {
+			t.Errorf("merged %d, want 2", res.Merged)
+		}
+	}
+	if kicks := r.Kicks(); len(kicks) != 1 || !slices.Equal(kicks[0], []string{"gw1", "gw2"}) {
+		t.Errorf("kicks %v, want single kick of [gw1 gw2]", kicks)
+	}
+}
+
+// notifications received while kick of the feed runs are merged into the next kick
+func TestCoalescerMergesWhileRunning(t *testing.T) {
+	r := &recorder{delay: 50 * time.Millisecond}
+	c := NewCoalescer(context.Background(), 0, r.kick)
+
+	first := c.Submit("feedME", []string{"gw1"})
+	time.Sleep(10 * time.Millisecond)
+	second := c.Submit("feedME", []string{"gw1"})
+	third := c.Submit("feedME", []string{"gw2"})
+
+	if res := receive(t, first); res.Merged != 1 {
+		t.Errorf("first kick merged %d, want 1", res.Merged)
+	}
+	for _, resCh := range []<-chan Result{second, third} {
+		if res := receive(t, resCh); res.Merged != 2 {
+			t.Errorf("next kick merged %d, want 2", res.Merged)
+		}
+	}
+	if kicks := r.Kicks(); len(kicks) != 2 {
+		t.Errorf("%d kicks, want 2", len(kicks))
+	}
+}
+
+func TestCoalescerKickError(t *testing.T) {
+	kickErr := errors.New("management API is down")
+	c := NewCoalescer(context.Background(), 0, func(ctx context.Context, feed string, targets []string) (*Report, error) {
+		return nil, kickErr
+	})
+
+	if res := receive(t, c.Submit("feedME", []string{"gw1"})); !errors.Is(res.Err, kickErr) {
+		t.Errorf("result error %v, want %v", res.Err, kickErr)
+	}
+}
+
+func TestCoalescerRefusesOnShutdown(t *testing.T) {
+	r := &recorder{}
+	ctx, cancel := context.WithCancel(context.Background())
+	c := NewCoalescer(ctx, time.Hour, r.kick)
+
+	pending := c.Submit("feedME", []string{"gw1"})
+	cancel()
+
+	if res := receive(t, pending); !errors.Is(res.Err, ErrShutdown) {
+		t.Errorf("pending kick error %v, want %v", res.Err, ErrShutdown)
+	}
+	if res := receive(t, c.SubmitUrgent("feedME", []string{"gw1"})); !errors.Is(res.Err, ErrShutdown) {
+		t.Errorf("kick submitted after shutdown error %v, want %v", res.Err, ErrShutdown)
+	}
+	if kicks := r.Kicks(); len(kicks) != 0 {
+		t.Errorf("kicks %v after shutdown, want none", kicks)
+	}
+}
@@ -0,0 +1,159 @@
+package kicker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Limiter protects gateways from excessive kicks
+// at most FeedKicksPerMinute kicks of a feed per minute (sliding window)
+// and at most GatewayConcurrency kicks running on a gateway at once
+// kicks over limits wait in queue of at most Backlog entries, zero values disable the limit
+// queued kick is dropped when its context is cancelled
+
+var ErrBacklogFull = errors.New("kick backlog is full")
+
+type Limiter struct {
+	FeedKicksPerMinute int
+	GatewayConcurrency int
+	Backlog            int
+
+	mu            sync.Mutex
+	feedKicks     map[string][]time.Time // feed -> start times of kicks within last minute
+	gatewayActive map[string]int         // gateway -> running kicks
+	queued        int
+	changed       chan struct{} // closed and replaced when gateway is released
+}
+
+func NewLimiter(feedKicksPerMinute, gatewayConcurrency, backlog int) *Limiter {
+	return &Limiter{
+		FeedKicksPerMinute: feedKicksPerMinute,
+		GatewayConcurrency: gatewayConcurrency,
+		Backlog:            backlog,
+		feedKicks:          make(map[string][]time.Time),
+		gatewayActive:      make(map[string]int),
+		changed:            make(chan struct{}),
+	}
+}
+
+// Wrap returns KickFunc which waits for limits before calling kick
+func (l *Limiter) Wrap(kick KickFunc) KickFunc {
+	return func(ctx context.Context, feed string, targets []string) (*Report, error) {
+		if err := l.acquire(ctx, feed, targets); err != nil {
+			return nil, err
+		}
+		defer l.release(targets)
+
+		return kick(ctx, feed, targets)
+	}
+}
+
+// Queued returns number of kicks waiting for limits
+func (l *Limiter) Queued() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.queued
+}
+
+func (l *Limiter) acquire(ctx context.Context, feed string, targets []string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	reason, wait := l.checkLocked(feed, targets)
+	if reason == "" {
+		l.takeLocked(feed, targets)
+		return nil
+	}
+
+	if l.Backlog > 0 && l.queued >= l.Backlog {
+		fmt.Fprintf(os.Stderr, "[Limiter] Kick of feed '%s' rejected: %s and backlog is full (%d)\n", feed, reason, l.Backlog)
+		return ErrBacklogFull
+	}
+
+	l.queued++
+	defer func() { l.queued-- }()
+	fmt.Fprintf(os.Stdout, "[Limiter] Kick of feed '%s' queued: %s (backlog %d/%d)\n", feed, reason, l.queued, l.Backlog)
+
+	queuedAt := time.Now()
+	for reason != "" {
+		changed := l.changed
+		l.mu.Unlock()
+
+		var timeout <-chan time.Time
+		if wait > 0 {
+			timeout = time.After(wait)
+		}
+		select {
+		case <-changed:
+		case <-timeout:
+		case <-ctx.Done():
+		}
+
+		l.mu.Lock()
+		if err := ctx.Err(); err != nil {
+			fmt.Fprintf(os.Stderr, "[Limiter] Kick of feed '%s' dropped from queue: %v\n", feed, err)
+			return err
+		}
+		reason, wait = l.checkLocked(feed, targets)
+	}
+	l.takeLocked(feed, targets)
+	fmt.Fprintf(os.Stdout, "[Limiter] Kick of feed '%s' dequeued after %s\n", feed, time.Since(queuedAt).Round(time.Millisecond))
+
+	return nil
+}
+
+// checkLocked returns reason why kick has to wait and how long for feed rate limit, empty reason if kick may start
+func (l *Limiter) checkLocked(feed string, targets []string) (string, time.Duration) {
+	if l.FeedKicksPerMinute > 0 {
+		// forget kicks older than a minute
+		recent := l.feedKicks[feed][:0]
+		for _, t := range l.feedKicks[feed] {
+			if time.Since(t) < time.Minute {
+				recent = append(recent, t)
+			}
+		}
+		l.feedKicks[feed] = recent
+
+		if len(recent) >= l.FeedKicksPerMinute {
+			return fmt.Sprintf("feed limit %d kicks per minute reached", l.FeedKicksPerMinute), time.Until(recent[0].Add(time.Minute))
+		}
+	}
+
+	if l.GatewayConcurrency > 0 {
+		for _, gw := range targets {
+			if l.gatewayActive[gw] >= l.GatewayConcurrency {
+				return fmt.Sprintf("gateway %s has %d kicks running", gw, l.gatewayActive[gw]), 0
+			}
+		}
+	}
+
+	return "", 0
+}
+
+func (l *Limiter) takeLocked(feed string, targets []string) {
+	l.feedKicks[feed] = append(l.feedKicks[feed], time.Now())
+	for _, gw := range targets {
+		l.gatewayActive[gw]++
+	}
+}
+
+func (l *Limiter) release(targets []string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, gw := range targets {
+		l.gatewayActive[gw]--
+		if l.gatewayActive[gw] <= 0 {
+			delete(l.gatewayActive, gw)
+		}
+	}
+
+	// wake up queued kicks
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
@@ -0,0 +1,135 @@
+package kicker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// blockingKick returns KickFunc reporting its targets and waiting for release
+func blockingKick(started chan<- []string, release <-chan struct{}) KickFunc {
+	return func(ctx context.Context, feed string, targets []string) (*Report, error) {
+		started <- targets
+		<-release
+		return &Report{Feed: feed}, nil
+	}
+}
+
+func TestLimiterGatewayConcurrency(t *testing.T) {
+	l := NewLimiter(0, 1, 0)
+	started := make(chan []string, 2)
+	release := make(chan struct{})
+	kick := l.Wrap(blockingKick(started, release))
+
+	done := make(chan error, 2)
+	go func() { _, err := kick(context.Background(), "feedME", []string{"gw1"}); done <- err }()
+	<-started
+	go func() { _, err := kick(context.Background(), "quiccloud", []string{"gw1"}); done <- err }()
+
+	// second kick waits for gateway
+	select {
+	case <-started:
+		t.Fatal("second kick on gateway started while first was running")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if queued := l.Queued(); queued != 1 {
+		t.Errorf("Queued() = %d, want 1", queued)
+	}
+
+	close(release)
+	for range 2 {
+		if err := <-done; err != nil {
+			t.Errorf("kick error %v", err)
+		}
+	}
+	if queued := l.Queued(); queued != 0 {
+		t.Errorf("Queued() = %d after kicks finished, want 0", queued)
+	}
+}
+
+func TestLimiterFeedRate(t *testing.T) {
+	l := NewLimiter(2, 0, 0)
+	kick := l.Wrap(func(ctx context.Context, feed string, targets []string) (*Report, error) {
+		return &Report{Feed: feed}, nil
+	})
+
+	for range 2 {
+		if _, err := kick(context.Background(), "feedME", []string{"gw1"}); err != nil {
+			t.Fatalf("kick within rate limit failed: %v", err)
+		}
+	}
+	// other feed is not limited
+	if _, err := kick(context.Background(), "quiccloud", []string{"gw1"}); err != nil {
+		t.Fatalf("kick of other feed failed: %v", err)
+	}
+
+	// third kick of feed within a minute waits
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := kick(ctx, "feedME", []string{"gw1"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("kick over rate limit error %v, want it to wait until context is done", err)
+	}
+}
+
+func TestLimiterBacklogFull(t *testing.T) {
+	l := NewLimiter(0, 1, 1)
+	started := make(chan []string, 3)
+	release := make(chan struct{})
+	kick := l.Wrap(blockingKick(started, release))
+
+	done := make(chan error, 3)
+	go func() { _, err := kick(context.Background(), "feedME", []string{"gw1"}); done <- err }()
+	<-started
+	go func() { _, err := kick(context.Background(), "feedME", []string{"gw1"}); done <- err }()
+	for l.Queued() < 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := kick(context.Background(), "feedME", []string{"gw1"}); !errors.Is(err, ErrBacklogFull) {
+		t.Errorf("kick over backlog error %v, want %v", err, ErrBacklogFull)
+	}
+
+	close(release)
+	for range 2 {
+		if err := <-done; err != nil {
+			t.Errorf("kick error %v", err)
+		}
+	}
+}
+
+func TestLimiterDropsQueuedOnCancel(t *testing.T) {
+	l := NewLimiter(0, 1, 0)
+	started := make(chan []string, 2)
+	release := make(chan struct{})
+	defer close(release)
+	kick := l.Wrap(blockingKick(started, release))
+
+	go kick(context.Background(), "feedME", []string{"gw1"})
+	<-started
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { _, err := kick(ctx, "feedME", []string{"gw1"}); done <- err }()
+	for l.Queued() < 1 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("queued kick error %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("queued kick was not dropped when its context was cancelled")
+	}
+	if queued := l.Queued(); queued != 0 {
+		t.Errorf("Queued() = %d after drop, want 0", queued)
+	}
+	select {
+	case <-started:
+		t.Error("dropped kick was started")
+	default:
+	}
+}
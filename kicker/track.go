@@ -0,0 +1,165 @@
+package kicker
+
+import (
+	"context"
+	"cpfeedman/cpapi"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// kicks are followed through show-task until run-script finishes on every gateway
+
+// StatusTimeout marks gateway which did not finish kick in time
+const StatusTimeout cpapi.TaskStatus = "timeout"
+
+// GatewayResult is outcome of kick on single gateway
+type GatewayResult struct {
+	Gateway    string
+	TaskID     string
+	Status     cpapi.TaskStatus // task status as reported by management
+	Succeeded  bool             // dynamic_objects -efo_update finished successfully
+	StatusCode string           // gateway status code from task-details
+	Message    string           // decoded responseMessage
+	Error      string           // decoded responseError
+	Duration   time.Duration    // from kick until task finished
+}
+
+// Report of kick on all targets
+type Report struct {
+	Feed     string
+	Gateways []GatewayResult
+	Duration time.Duration
+}
+
+func (r *Report) Failed() []GatewayResult {
+	failed := make([]GatewayResult, 0)
+	if r == nil {
+		return failed
+	}
+	for _, gw := range r.Gateways {
+		if !gw.Succeeded {
+			failed = append(failed, gw)
+		}
+	}
+	return failed
+}
+
+// Err returns error naming failed gateways, nil if kick succeeded everywhere
+func (r *Report) Err() error {
+	failed := r.Failed()
+	if len(failed) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(failed))
+	for _, gw := range failed {
+		names = append(names, gw.Gateway+" ("+string(gw.Status)+")")
+	}
+	return fmt.Errorf("kick of feed %s failed on gateways: %s", r.Feed, strings.Join(names, ", "))
+}
+
+// DefaultKickTimeout is used when kick timeout is not positive
+const DefaultKickTimeout = 2 * time.Minute
+
+// NewKickFunc kicks feed using Management API and waits up to timeout for run-script tasks to finish
+// started run-scripts are followed until tracking is cancelled, even when kick's context was cancelled
+// meanwhile - gateways which did not finish by then are reported as timed out
+func NewKickFunc(tracking context.Context, cpApi *cpapi.CpApi, timeout time.Duration) KickFunc {
+	if timeout <= 0 {
+		timeout = DefaultKickTimeout
+	}
+	return func(ctx context.Context, feed string, targets []string) (*Report, error) {
+		if ctx.Err() != nil {
+			fmt.Fprintf(os.Stderr, "[Kicker] Not kicking feed '%s', shutting down\n", feed)
+			return nil, ErrShutdown
+		}
+		fmt.Fprintf(os.Stdout, "[Kicker] Kicking feed '%s' on gateways: %v\n", feed, targets)
+
+		startTime := time.Now()
+		resp, err := cpApi.KickFeed(feed, targets)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[Kicker] Error kicking feed '%s': %v\n", feed, err)
+			return nil, err
+		}
+		fmt.Fprintf(os.Stdout, "[Kicker] Kick feed tasks for '%s': %v\n", feed, resp.GetTaskIds())
+
+		report, err := trackKick(tracking, cpApi, feed, resp, startTime, timeout)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[Kicker] Error tracking kick of feed '%s': %v\n", feed, err)
+			return report, err
+		}
+
+		for _, gw := range report.Gateways {
+			out := os.Stdout
+			if !gw.Succeeded {
+				out = os.Stderr
+			}
+			fmt.Fprintf(out, "[Kicker] Feed '%s' on %s: %s in %s\n", feed, gw.Gateway, gw.Status, gw.Duration.Round(time.Millisecond))
+			if gw.Message != "" {
+				fmt.Fprintf(out, "===\n%s===\n", gw.Message)
+			}
+			if gw.Error != "" {
+				fmt.Fprintf(out, "[Kicker] Error output:\n===\n%s===\n", gw.Error)
+			}
+		}
+
+		return report, report.Err()
+	}
+}
+
+func trackKick(tracking context.Context, cpApi *cpapi.CpApi, feed string, resp *cpapi.RunScriptResponse, startTime time.Time, timeout time.Duration) (*Report, error) {
+	taskTargets := resp.GetTaskTargets()
+
+	ctx, cancel := context.WithTimeout(tracking, timeout-time.Since(startTime))
+	defer cancel()
+
+	waitStart := time.Now()
+	lastFinished := 0
+	result, err := cpApi.WaitForTasks(ctx, resp.GetTaskIds(), &cpapi.WaitOptions{
+		OnProgress: func(p cpapi.TaskProgress) {
+			if p.Finished == lastFinished || p.Finished == p.Total {
+				return
+			}
+			lastFinished = p.Finished
+			fmt.Fprintf(os.Stdout, "[Kicker] Feed '%s': %d/%d gateways finished (%d%%)\n", feed, p.Finished, p.Total, p.Percentage)
+		},
+	})
+	if err != nil && !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, context.Canceled) {
+		return nil, fmt.Errorf("failed to get kick task results: %w", err)
+	}
+
+	report := &Report{Feed: feed, Duration: time.Since(startTime)}
+	for _, task := range result.Tasks {
+		res := GatewayResult{
+			Gateway:   taskTargets[task.TaskID],
+			TaskID:    task.TaskID,
+			Status:    task.Status,
+			Succeeded: task.Status.IsSucceeded(),
+			Duration:  waitStart.Sub(startTime) + task.FinishedAfter,
+		}
+		if task.Status.IsPending() {
+			res.Status = StatusTimeout
+			res.Duration = report.Duration
+		}
+
+		// kick task has single target, collect output of all reported gateways anyway
+		var messages, errs []string
+		for _, gwResult := range task.GetGatewayResults() {
+			res.StatusCode = gwResult.StatusCode
+			messages = append(messages, gwResult.Message)
+			errs = append(errs, gwResult.Error)
+			if gwResult.DecodeErr != nil {
+				errs = append(errs, gwResult.DecodeErr.Error()+"\n")
+			}
+		}
+		res.Message = strings.Join(messages, "")
+		res.Error = strings.Join(errs, "")
+
+		report.Gateways = append(report.Gateways, res)
+	}
+
+	return report, nil
+}
@@ -0,0 +1,237 @@
+package mqttin
+
+import (
+	"context"
+	"cpfeedman/input"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// MQTTIn represents MQTT input - subscribes topics mapped to feeds, implements input.Source
+// topic rule "<topic filter>=<feed>" - filter may use + and # wildcards,
+// feed "*" stands for the last topic level, e.g.
+//   intel/feeds/+=*            intel/feeds/feedME -> feedME
+//   branch/+/quic/#=quiccloud  branch/b12/quic/v4 -> quiccloud
+// message on matching topic notifies the feed, payload is logged only
+//
+// subscriptions use QoS 1 with persistent session (clean session off, fixed client ID),
+// so messages published while cpfeedman is down are delivered after reconnect
+// message is acknowledged after handler finishes - Retry is handled again after RetryDelay
+// (up to MaxRetries times), Reject is acknowledged as MQTT has no negative acknowledgement
+
+// expecting broker URL, e.g. tcp://broker:1883 or ssl://broker:8883
+
+type TopicRule struct {
+	Filter string
+	Feed   string
+}
+
+type MQTTIn struct {
+	Broker     string
+	ClientID   string
+	Username   string
+	Password   string
+	TopicRules []TopicRule
+	TLSConfig  *tls.Config // optional, e.g. from NewTLSConfig
+
+	input.Retrier
+	input.Stopper
+}
+
+func NewMQTTIn(broker string, clientID string, topicRules []TopicRule) *MQTTIn {
+	return &MQTTIn{
+		Broker:     broker,
+		ClientID:   clientID,
+		TopicRules: topicRules,
+		Retrier:    input.NewRetrier(),
+	}
+}
+
+// ParseTopicRules parses "<topic filter>=<feed>" rule specifications
+func ParseTopicRules(specs []string) ([]TopicRule, error) {
+	rules := make([]TopicRule, 0, len(specs))
+	for _, spec := range specs {
+		filter, feed, ok := strings.Cut(spec, "=")
+		filter, feed = strings.TrimSpace(filter), strings.TrimSpace(feed)
+		if !ok || filter == "" || feed == "" {
+			return nil, fmt.Errorf("invalid MQTT topic rule %q, expecting <topic filter>=<feed>", spec)
+		}
+		rules = append(rules, TopicRule{Filter: filter, Feed: feed})
+	}
+	return rules, nil
+}
+
+// NewTLSConfig loads CA and client certificate for mutual TLS, empty file names are skipped
+func NewTLSConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if caFile != "" {
+		caPem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caPem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", caFile)
+		}
+	}
+
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// Match returns feed for topic, empty if rule does not match
+func (r TopicRule) Match(topic string) string {
+	if !topicMatches(r.Filter, topic) {
+		return ""
+	}
+	if r.Feed == "*" {
+		levels := strings.Split(topic, "/")
+		return levels[len(levels)-1]
+	}
+	return r.Feed
+}
+
+// topicMatches implements MQTT topic filter matching
+func topicMatches(filter, topic string) bool {
+	filterLevels := strings.Split(filter, "/")
+	topicLevels := strings.Split(topic, "/")
+	for i, level := range filterLevels {
+		if level == "#" {
+			return true
+		}
+		if i >= len(topicLevels) {
+			return false
+		}
+		if level != "+" && level != topicLevels[i] {
+			return false
+		}
+	}
+	return len(filterLevels) == len(topicLevels)
+}
+
+// Name is MQTT with broker, e.g. MQTT:tcp://broker:1883
+func (m *MQTTIn) Name() string {
+	return "MQTT:" + m.Broker
+}
+
+func (m *MQTTIn) Listen(handler input.Handler) error {
+	ctx, cancel := m.Listening()
+	defer cancel()
+
+	var (
+		inFlightMu sync.Mutex // orders adding of handlers with waiting for them
+		inFlight   sync.WaitGroup
+	)
+
+	filters := make(map[string]byte, len(m.TopicRules))
+	for _, rule := range m.TopicRules {
+		filters[rule.Filter] = 1 // QoS 1
+	}
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(m.Broker).
+		SetClientID(m.ClientID).
+		SetUsername(m.Username).
+		SetPassword(m.Password).
+		SetCleanSession(false).
+		SetAutoAckDisabled(true).
+		SetOrderMatters(false).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetMaxReconnectInterval(time.Minute).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			log.Printf("[MQTTIN] connection lost, reconnecting: %v", err)
+		}).
+		SetOnConnectHandler(func(c mqtt.Client) {
+			log.Printf("[MQTTIN] Connected to %s, subscribing %v", m.Broker, filters)
+			token := c.SubscribeMultiple(filters, func(_ mqtt.Client, msg mqtt.Message) {
+				inFlightMu.Lock()
+				if ctx.Err() != nil {
+					// stopping - broker redelivers unacknowledged message in next session
+					inFlightMu.Unlock()
+					return
+				}
+				inFlight.Add(1)
+				inFlightMu.Unlock()
+
+				defer inFlight.Done()
+				m.handle(ctx, handler, msg)
+			})
+			if token.Wait() && token.Error() != nil {
+				log.Printf("[MQTTIN] failed to subscribe: %v", token.Error())
+			}
+		})
+	if m.TLSConfig != nil {
+		opts.SetTLSConfig(m.TLSConfig)
+	}
+
+	client := mqtt.NewClient(opts)
+	defer client.Disconnect(1000)
+
+	// connect is retried until broker is reachable or stopped
+	token := client.Connect()
+	for !token.WaitTimeout(time.Second) {
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+	if token.Error() != nil {
+		return fmt.Errorf("failed to connect: %w", token.Error())
+	}
+
+	<-ctx.Done()
+
+	// callbacks taking the lock from now on see cancelled ctx and skip the message,
+	// let running handlers acknowledge messages before disconnecting
+	inFlightMu.Lock()
+	inFlight.Wait()
+	inFlightMu.Unlock()
+
+	return nil
+}
+
+func (m *MQTTIn) handle(ctx context.Context, handler input.Handler, msg mqtt.Message) {
+	log.Printf("[MQTTIN] Received message on %s: %s", msg.Topic(), msg.Payload())
+
+	feed := ""
+	for _, rule := range m.TopicRules {
+		if feed = rule.Match(msg.Topic()); feed != "" {
+			break
+		}
+	}
+	if feed == "" {
+		log.Printf("[MQTTIN] No topic rule matches %s", msg.Topic())
+		msg.Ack()
+		return
+	}
+
+	outcome, ok := m.HandleRetrying(ctx, handler, &input.Message{
+		Source:     m.Name(),
+		ID:         fmt.Sprint(msg.MessageID()),
+		Body:       feed,
+		Attributes: map[string]string{"mqtt-topic": msg.Topic()},
+	})
+	if !ok {
+		return // stopping - broker redelivers unacknowledged message in next session
+	}
+	if outcome == input.Retry {
+		log.Printf("[MQTTIN] Giving up message on %s after %d retries", msg.Topic(), m.MaxRetries)
+	}
+	msg.Ack()
+}
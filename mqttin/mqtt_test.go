@@ -0,0 +1,64 @@
+package mqttin
+
+import (
+	"testing"
+)
+
+func TestTopicMatches(t *testing.T) {
+	tests := []struct {
+		filter, topic string
+		want          bool
+	}{
+		{"intel/feeds/feedME", "intel/feeds/feedME", true},
+		{"intel/feeds/feedME", "intel/feeds/other", false},
+		{"intel/feeds/+", "intel/feeds/feedME", true},
+		{"intel/feeds/+", "intel/feeds", false},
+		{"intel/feeds/+", "intel/feeds/feedME/v2", false},
+		{"intel/feeds/+", "intel/feeds/", true},
+		{"branch/+/quic/#", "branch/b12/quic/v4", true},
+		{"branch/+/quic/#", "branch/b12/quic/v4/extra", true},
+		{"branch/+/quic/#", "branch/b12/quic", true},
+		{"branch/+/quic/#", "branch/b12/other/v4", false},
+		{"#", "any/topic", true},
+		{"+/+", "a/b", true},
+		{"+/+", "a", false},
+	}
+	for _, tt := range tests {
+		if got := topicMatches(tt.filter, tt.topic); got != tt.want {
+			t.Errorf("topicMatches(%q, %q) = %v, want %v", tt.filter, tt.topic, got, tt.want)
+		}
+	}
+}
+
+func TestTopicRuleMatch(t *testing.T) {
+	tests := []struct {
+		rule  TopicRule
+		topic string
+		want  string
+	}{
+		{TopicRule{"intel/feeds/+", "*"}, "intel/feeds/feedME", "feedME"},
+		{TopicRule{"branch/+/quic/#", "quiccloud"}, "branch/b12/quic/v4", "quiccloud"},
+		{TopicRule{"branch/+/quic/#", "quiccloud"}, "branch/b12/other", ""},
+	}
+	for _, tt := range tests {
+		if got := tt.rule.Match(tt.topic); got != tt.want {
+			t.Errorf("%+v.Match(%q) = %q, want %q", tt.rule, tt.topic, got, tt.want)
+		}
+	}
+}
+
+func TestParseTopicRules(t *testing.T) {
+	rules, err := ParseTopicRules([]string{" intel/feeds/+ = * ", "branch/+/quic/#=quiccloud"})
+	if err != nil {
+		t.Fatalf("ParseTopicRules() error %v", err)
+	}
+	if len(rules) != 2 || rules[0] != (TopicRule{"intel/feeds/+", "*"}) || rules[1] != (TopicRule{"branch/+/quic/#", "quiccloud"}) {
+		t.Errorf("ParseTopicRules() = %+v", rules)
+	}
+
+	for _, spec := range []string{"intel/feeds/+", "=feedME", "intel/feeds/+="} {
+		if _, err := ParseTopicRules([]string{spec}); err == nil {
+			t.Errorf("ParseTopicRules(%q) accepted invalid rule", spec)
+		}
+	}
+}
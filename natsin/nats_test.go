@@ -0,0 +1,65 @@
+package natsin
+
+import (
+	"testing"
+)
+
+func TestSubjectMatches(t *testing.T) {
+	tests := []struct {
+		filter, subject string
+		want            bool
+	}{
+		{"feeds.feedME", "feeds.feedME", true},
+		{"feeds.feedME", "feeds.other", false},
+		{"feeds.*", "feeds.feedME", true},
+		{"feeds.*", "feeds", false},
+		{"feeds.*", "feeds.feedME.v2", false},
+		{"branch.*.quic.>", "branch.b12.quic.v4", true},
+		{"branch.*.quic.>", "branch.b12.quic.v4.extra", true},
+		{"branch.*.quic.>", "branch.b12.quic", false},
+		{"branch.*.quic.>", "branch.b12.other.v4", false},
+		{">", "any.subject", true},
+		{"*.*", "a.b", true},
+		{"*.*", "a", false},
+	}
+	for _, tt := range tests {
+		if got := subjectMatches(tt.filter, tt.subject); got != tt.want {
+			t.Errorf("subjectMatches(%q, %q) = %v, want %v", tt.filter, tt.subject, got, tt.want)
+		}
+	}
+}
+
+func TestSubjectRuleMatch(t *testing.T) {
+	tests := []struct {
+		rule     SubjectRule
+		subject  string
+		wantFeed string
+		wantOk   bool
+	}{
+		{SubjectRule{"feeds.*", "*"}, "feeds.feedME", "feedME", true},
+		{SubjectRule{"branch.*.quic.>", "quiccloud"}, "branch.b12.quic.v4", "quiccloud", true},
+		{SubjectRule{"notifications.>", ""}, "notifications.ci", "", true},
+		{SubjectRule{"feeds.*", "*"}, "other.feedME", "", false},
+	}
+	for _, tt := range tests {
+		if feed, ok := tt.rule.Match(tt.subject); feed != tt.wantFeed || ok != tt.wantOk {
+			t.Errorf("%+v.Match(%q) = %q, %v, want %q, %v", tt.rule, tt.subject, feed, ok, tt.wantFeed, tt.wantOk)
+		}
+	}
+}
+
+func TestParseSubjectRules(t *testing.T) {
+	rules, err := ParseSubjectRules([]string{" feeds.* = * ", "notifications.>"})
+	if err != nil {
+		t.Fatalf("ParseSubjectRules() error %v", err)
+	}
+	if len(rules) != 2 || rules[0] != (SubjectRule{"feeds.*", "*"}) || rules[1] != (SubjectRule{"notifications.>", ""}) {
+		t.Errorf("ParseSubjectRules() = %+v", rules)
+	}
+
+	for _, spec := range []string{"", "=feedME", "feeds.*="} {
+		if _, err := ParseSubjectRules([]string{spec}); err == nil {
+			t.Errorf("ParseSubjectRules(%q) accepted invalid rule", spec)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package notification
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// CloudEvents 1.0 notifications
+// structured mode - whole event is JSON message body
+// binary mode - event attributes are message attributes prefixed with "ce-" (e.g. ce-type), body is event data
+//
+// event type has to be one of Decoder.CloudEventTypes, events of other types are ignored
+// subject is feed name, data is optional JSON notification (see Parse) or JSON string with feed name
+// id and source default notification correlation-id and requester
+
+const DefaultCloudEventType = "com.checkpoint.feed.changed"
+
+const cloudEventsAttributePrefix = "ce-"
+
+type CloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	Type            string          `json:"type"`
+	Source          string          `json:"source"`
+	ID              string          `json:"id"`
+	Subject         string          `json:"subject,omitempty"`
+	Time            string          `json:"time,omitempty"`
+	DataContentType string          `json:"datacontenttype,omitempty"`
+	Data            json.RawMessage `json:"data,omitempty"`
+}
+
+// cloudEventFromAttributes builds binary mode event, nil if attributes do not carry CloudEvent
+func cloudEventFromAttributes(attributes map[string]string, body string) *CloudEvent {
+	// attribute names are case-insensitive
+	lowered := make(map[string]string, len(attributes))
+	for name, value := range attributes {
+		lowered[strings.ToLower(name)] = value
+	}
+	attr := func(name string) string {
+		return lowered[cloudEventsAttributePrefix+name]
+	}
+	if attr("specversion") == "" {
+		return nil
+	}
+
+	ce := &CloudEvent{
+		SpecVersion:     attr("specversion"),
+		Type:            attr("type"),
+		Source:          attr("source"),
+		ID:              attr("id"),
+		Subject:         attr("subject"),
+		Time:            attr("time"),
+		DataContentType: lowered["content-type"],
+	}
+
+	body = strings.TrimSpace(body)
+	switch {
+	case body == "":
+	case json.Valid([]byte(body)):
+		ce.Data = json.RawMessage(body)
+	default:
+		// plain text data - feed name
+		quoted, _ := json.Marshal(body)
+		ce.Data = quoted
+	}
+
+	return ce
+}
+
+func (d *Decoder) cloudEventNotifications(ce *CloudEvent) ([]*Notification, error) {
+	if !strings.HasPrefix(ce.SpecVersion, "1.") {
+		return nil, fmt.Errorf("unsupported CloudEvents specversion %q", ce.SpecVersion)
+	}
+	if ce.Type == "" || ce.Source == "" || ce.ID == "" {
+		return nil, fmt.Errorf("CloudEvent is missing required attribute type, source or id")
+	}
+	if !slices.Contains(d.CloudEventTypes, ce.Type) {
+		fmt.Fprintf(os.Stdout, "[Decode] Ignoring CloudEvent of type %s from %s\n", ce.Type, ce.Source)
+		return []*Notification{}, nil
+	}
+
+	n := &Notification{}
+	data := strings.TrimSpace(string(ce.Data))
+	switch {
+	case data == "" || data == "null":
+	case strings.HasPrefix(data, "{"):
+		var err error
+		if n, err = decodeJSON([]byte(data)); err != nil {
+			return nil, fmt.Errorf("invalid CloudEvent data: %w", err)
+		}
+	case strings.HasPrefix(data, "\""):
+		if err := json.Unmarshal([]byte(data), &n.Feed); err != nil {
+			return nil, fmt.Errorf("invalid CloudEvent data: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported CloudEvent data: %s", data)
+	}
+
+	if n.Feed == "" && n.FeedUID == "" {
+		n.Feed = ce.Subject
+	}
+	if n.CorrelationID == "" {
+		n.CorrelationID = ce.ID
+	}
+	if n.Requester == "" {
+		n.Requester = ce.Source
+	}
+	n.setDefaults()
+
+	if err := n.Validate(); err != nil {
+		return nil, err
+	}
+
+	return []*Notification{n}, nil
+}
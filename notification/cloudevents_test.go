@@ -0,0 +1,53 @@
+package notification
+
+import (
+	"testing"
+)
+
+func TestDecodeCloudEvent(t *testing.T) {
+	decoder := NewDecoder(nil, []string{DefaultCloudEventType})
+
+	testDecode(t, decoder, []decodeTest{
+		{
+			name: "structured CloudEvent with subject",
+			body: `{"specversion": "1.0", "type": "com.checkpoint.feed.changed", "source": "/ci", "id": "e-1", "subject": "feedME"}`,
+			want: []Notification{{Feed: "feedME", Priority: PriorityNormal, Requester: "/ci", CorrelationID: "e-1"}},
+		},
+		{
+			name: "structured CloudEvent with notification data",
+			body: `{"specversion": "1.0", "type": "com.checkpoint.feed.changed", "source": "/ci", "id": "e-1",
+				"data": {"feed": "quiccloud", "priority": "high", "correlation-id": "c-2"}}`,
+			want: []Notification{{Feed: "quiccloud", Priority: PriorityHigh, Requester: "/ci", CorrelationID: "c-2"}},
+		},
+		{
+			name: "CloudEvent of other type",
+			body: `{"specversion": "1.0", "type": "com.example.other", "source": "/ci", "id": "e-1", "subject": "feedME"}`,
+			want: []Notification{},
+		},
+		{
+			name:    "CloudEvent without id",
+			body:    `{"specversion": "1.0", "type": "com.checkpoint.feed.changed", "source": "/ci", "subject": "feedME"}`,
+			wantErr: true,
+		},
+		{
+			name:    "CloudEvent of unsupported specversion",
+			body:    `{"specversion": "2.0", "type": "com.checkpoint.feed.changed", "source": "/ci", "id": "e-1", "subject": "feedME"}`,
+			wantErr: true,
+		},
+		{
+			name: "binary CloudEvent with plain data",
+			body: "feedME",
+			attributes: map[string]string{
+				"ce-specversion": "1.0", "CE-Type": "com.checkpoint.feed.changed", "ce-source": "/kafka", "ce-id": "e-3",
+			},
+			want: []Notification{{Feed: "feedME", Priority: PriorityNormal, Requester: "/kafka", CorrelationID: "e-3"}},
+		},
+		{
+			name: "binary CloudEvent with subject",
+			attributes: map[string]string{
+				"ce-specversion": "1.0", "ce-type": "com.checkpoint.feed.changed", "ce-source": "/kafka", "ce-id": "e-4", "ce-subject": "feedME",
+			},
+			want: []Notification{{Feed: "feedME", Priority: PriorityNormal, Requester: "/kafka", CorrelationID: "e-4"}},
+		},
+	})
+}
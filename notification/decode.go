@@ -0,0 +1,111 @@
+package notification
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Decoder turns message body into notifications
+// besides plain and JSON notifications (see Parse) it unwraps
+// SNS notification envelopes, S3 event notifications (S3 -> SNS -> SQS) and CloudEvents
+
+type Decoder struct {
+	S3Rules         []S3Rule
+	CloudEventTypes []string // accepted CloudEvent types
+}
+
+func NewDecoder(s3Rules []S3Rule, cloudEventTypes []string) *Decoder {
+	return &Decoder{S3Rules: s3Rules, CloudEventTypes: cloudEventTypes}
+}
+
+// SNSEnvelope wraps messages delivered from SNS topic without raw message delivery
+type SNSEnvelope struct {
+	Type      string `json:"Type"`
+	MessageId string `json:"MessageId"`
+	TopicArn  string `json:"TopicArn"`
+	Subject   string `json:"Subject"`
+	Message   string `json:"Message"`
+	Timestamp string `json:"Timestamp"`
+}
+
+// envelope keys used to recognize message kind
+type probe struct {
+	SpecVersion string            `json:"specversion"`
+	Type        string            `json:"Type"`
+	TopicArn    string            `json:"TopicArn"`
+	Records     []json.RawMessage `json:"Records"`
+	Event       string            `json:"Event"`
+}
+
+// Decode returns notifications carried by body and message attributes (headers)
+// empty result (without error) means message is valid but does not ask for any kick
+func (d *Decoder) Decode(body string, attributes map[string]string) ([]*Notification, error) {
+	// CloudEvent in binary mode
+	if ce := cloudEventFromAttributes(attributes, body); ce != nil {
+		return d.cloudEventNotifications(ce)
+	}
+
+	return d.decode(body, 0)
+}
+
+func (d *Decoder) decode(body string, depth int) ([]*Notification, error) {
+	body = strings.TrimSpace(body)
+	if depth > 2 {
+		return nil, fmt.Errorf("too deeply nested message")
+	}
+
+	if strings.HasPrefix(body, "{") {
+		var p probe
+		if err := json.Unmarshal([]byte(body), &p); err != nil {
+			return nil, fmt.Errorf("invalid notification JSON: %w", err)
+		}
+
+		switch {
+		case p.SpecVersion != "":
+			var ce CloudEvent
+			if err := json.Unmarshal([]byte(body), &ce); err != nil {
+				return nil, fmt.Errorf("invalid CloudEvent: %w", err)
+			}
+			return d.cloudEventNotifications(&ce)
+		case p.TopicArn != "" && p.Type != "":
+			return d.decodeSNS(body, depth)
+		case p.Records != nil || p.Event == "s3:TestEvent":
+			var event S3Event
+			if err := json.Unmarshal([]byte(body), &event); err != nil {
+				return nil, fmt.Errorf("invalid S3 event: %w", err)
+			}
+			return d.s3Notifications(&event), nil
+		}
+	}
+
+	n, err := Parse(body)
+	if err != nil {
+		return nil, err
+	}
+	return []*Notification{n}, nil
+}
+
+func (d *Decoder) decodeSNS(body string, depth int) ([]*Notification, error) {
+	var envelope SNSEnvelope
+	if err := json.Unmarshal([]byte(body), &envelope); err != nil {
+		return nil, fmt.Errorf("invalid SNS envelope: %w", err)
+	}
+
+	if envelope.Type != "Notification" {
+		fmt.Fprintf(os.Stdout, "[Decode] Ignoring SNS %s from %s\n", envelope.Type, envelope.TopicArn)
+		return []*Notification{}, nil
+	}
+
+	notifications, err := d.decode(envelope.Message, depth+1)
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range notifications {
+		if n.CorrelationID == "" {
+			n.CorrelationID = envelope.MessageId
+		}
+	}
+	return notifications, nil
+}
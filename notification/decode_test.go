@@ -0,0 +1,96 @@
+package notification
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const s3Event = `{"Records": [
+	{"eventSource": "aws:s3", "eventName": "ObjectCreated:Put",
+	 "userIdentity": {"principalId": "AWS:publisher"}, "responseElements": {"x-amz-request-id": "req-1"},
+	 "s3": {"bucket": {"name": "feeds-bucket"}, "object": {"key": "feeds/feed%2BME.txt"}}},
+	{"eventSource": "aws:s3", "eventName": "ObjectRemoved:Delete",
+	 "s3": {"bucket": {"name": "feeds-bucket"}, "object": {"key": "feeds/removed.txt"}}},
+	{"eventSource": "aws:s3", "eventName": "ObjectCreated:Put",
+	 "s3": {"bucket": {"name": "feeds-bucket"}, "object": {"key": "other/unmatched.txt"}}}
+]}`
+
+func snsEnvelope(t *testing.T, msgType, message string) string {
+	t.Helper()
+	body, err := json.Marshal(&SNSEnvelope{
+		Type:      msgType,
+		MessageId: "sns-1",
+		TopicArn:  "arn:aws:sns:eu-west-1:123456789012:feeds",
+		Message:   message,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+type decodeTest struct {
+	name       string
+	body       string
+	attributes map[string]string
+	want       []Notification // compared fields: Feed, FeedUID, Priority, Requester, CorrelationID
+	wantErr    bool
+}
+
+func TestDecode(t *testing.T) {
+	decoder := NewDecoder([]S3Rule{{Bucket: "feeds-bucket", KeyPattern: "feeds/*.txt", Feed: "*"}}, nil)
+
+	testDecode(t, decoder, []decodeTest{
+		{
+			name: "plain feed name",
+			body: " feedME\n",
+			want: []Notification{{Feed: "feedME", Priority: PriorityNormal}},
+		},
+		{name: "invalid JSON", body: `{"feed": `, wantErr: true},
+		{
+			name: "S3 event",
+			body: s3Event,
+			want: []Notification{{Feed: "feed+ME", Priority: PriorityNormal, Requester: "AWS:publisher", CorrelationID: "req-1"}},
+		},
+		{name: "S3 test event", body: `{"Event": "s3:TestEvent"}`, want: []Notification{}},
+		{
+			name: "SNS envelope",
+			body: snsEnvelope(t, "Notification", "feedME"),
+			want: []Notification{{Feed: "feedME", Priority: PriorityNormal, CorrelationID: "sns-1"}},
+		},
+		{
+			name: "S3 event in SNS envelope",
+			body: snsEnvelope(t, "Notification", s3Event),
+			want: []Notification{{Feed: "feed+ME", Priority: PriorityNormal, Requester: "AWS:publisher", CorrelationID: "req-1"}},
+		},
+		{name: "SNS subscription confirmation", body: snsEnvelope(t, "SubscriptionConfirmation", ""), want: []Notification{}},
+	})
+}
+
+func testDecode(t *testing.T, decoder *Decoder, tests []decodeTest) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decoder.Decode(tt.body, tt.attributes)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Decode() = %v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Decode() error %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Decode() = %v, want %d notifications", got, len(tt.want))
+			}
+			for i, n := range got {
+				w := tt.want[i]
+				if n.Version != CurrentVersion || n.Feed != w.Feed || n.FeedUID != w.FeedUID || n.Priority != w.Priority ||
+					n.Requester != w.Requester || n.CorrelationID != w.CorrelationID {
+					t.Errorf("notification %d = %s, want %s", i, n, &w)
+				}
+			}
+		})
+	}
+}
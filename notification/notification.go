@@ -0,0 +1,128 @@
+package notification
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Notification asks cpfeedman to update a feed on gateways
+// message body is either legacy plain feed name, e.g.
+//   feedME
+// or versioned JSON document, e.g.
+//   {"version": 1, "feed": "feedME", "gateways": ["gw10"], "priority": "high",
+//    "requester": "ci-job-42", "correlation-id": "6f1c...", "reason": "new indicators"}
+
+const CurrentVersion = 1
+
+const (
+	PriorityNormal = "normal" // kick after debounce window
+	PriorityHigh   = "high"   // kick immediately, skip debounce window
+)
+
+type Notification struct {
+	Version       int      `json:"version"`
+	Feed          string   `json:"feed,omitempty"`           // feed name
+	FeedUID       string   `json:"feed-uid,omitempty"`       // feed UID, alternative to feed name
+	Gateways      []string `json:"gateways,omitempty"`       // optional, narrows kick to these gateways
+	GatewayGroups []string `json:"gateway-groups,omitempty"` // optional, narrows kick to members of these groups
+	Priority      string   `json:"priority,omitempty"`       // normal (default) or high
+	Requester     string   `json:"requester,omitempty"`      // who asked for the kick
+	CorrelationID string   `json:"correlation-id,omitempty"` // publisher's ID to trace the kick
+	Reason        string   `json:"reason,omitempty"`         // free text, logged
+}
+
+var ErrEmpty = errors.New("empty notification")
+
+// Parse decodes message body in legacy or JSON form
+func Parse(body string) (*Notification, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return nil, ErrEmpty
+	}
+
+	// legacy - bare feed name
+	if !strings.HasPrefix(body, "{") {
+		return &Notification{Version: CurrentVersion, Feed: body, Priority: PriorityNormal}, nil
+	}
+
+	n, err := decodeJSON([]byte(body))
+	if err != nil {
+		return nil, err
+	}
+	n.setDefaults()
+
+	if err := n.Validate(); err != nil {
+		return nil, err
+	}
+
+	return n, nil
+}
+
+// decodeJSON decodes JSON notification without validation
+func decodeJSON(data []byte) (*Notification, error) {
+	var n Notification
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&n); err != nil {
+		return nil, fmt.Errorf("invalid notification JSON: %w", err)
+	}
+	return &n, nil
+}
+
+func (n *Notification) setDefaults() {
+	if n.Version == 0 {
+		n.Version = CurrentVersion
+	}
+	if n.Priority == "" {
+		n.Priority = PriorityNormal
+	}
+}
+
+func (n *Notification) Validate() error {
+	if n.Version != CurrentVersion {
+		return fmt.Errorf("unsupported notification version %d", n.Version)
+	}
+	if n.Feed == "" && n.FeedUID == "" {
+		return errors.New("notification has neither feed nor feed-uid")
+	}
+	if n.Priority != PriorityNormal && n.Priority != PriorityHigh {
+		return fmt.Errorf("unsupported notification priority %q", n.Priority)
+	}
+	return nil
+}
+
+// HasTargets is true when notification narrows gateways to kick
+func (n *Notification) HasTargets() bool {
+	return len(n.Gateways) > 0 || len(n.GatewayGroups) > 0
+}
+
+// String for logging
+func (n *Notification) String() string {
+	parts := []string{}
+	if n.Feed != "" {
+		parts = append(parts, "feed="+n.Feed)
+	}
+	if n.FeedUID != "" {
+		parts = append(parts, "feed-uid="+n.FeedUID)
+	}
+	if len(n.Gateways) > 0 {
+		parts = append(parts, "gateways="+strings.Join(n.Gateways, ","))
+	}
+	if len(n.GatewayGroups) > 0 {
+		parts = append(parts, "gateway-groups="+strings.Join(n.GatewayGroups, ","))
+	}
+	parts = append(parts, "priority="+n.Priority)
+	if n.Requester != "" {
+		parts = append(parts, "requester="+n.Requester)
+	}
+	if n.CorrelationID != "" {
+		parts = append(parts, "correlation-id="+n.CorrelationID)
+	}
+	if n.Reason != "" {
+		parts = append(parts, fmt.Sprintf("reason=%q", n.Reason))
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,60 @@
+package notification
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    Notification // compared fields: Version, Feed, FeedUID, Priority, Requester, CorrelationID
+		wantErr bool
+	}{
+		{
+			name: "plain feed name",
+			body: " feedME\n",
+			want: Notification{Version: CurrentVersion, Feed: "feedME", Priority: PriorityNormal},
+		},
+		{
+			name: "JSON notification",
+			body: `{"version": 1, "feed-uid": "uid-1", "priority": "high", "requester": "ci", "correlation-id": "c-1"}`,
+			want: Notification{Version: CurrentVersion, FeedUID: "uid-1", Priority: PriorityHigh, Requester: "ci", CorrelationID: "c-1"},
+		},
+		{
+			name: "JSON notification with defaults",
+			body: `{"feed": "feedME"}`,
+			want: Notification{Version: CurrentVersion, Feed: "feedME", Priority: PriorityNormal},
+		},
+		{name: "invalid JSON", body: `{"feed": `, wantErr: true},
+		{name: "unknown JSON field", body: `{"feed": "feedME", "colour": "red"}`, wantErr: true},
+		{name: "unsupported version", body: `{"version": 2, "feed": "feedME"}`, wantErr: true},
+		{name: "no feed", body: `{"version": 1, "priority": "high"}`, wantErr: true},
+		{name: "unsupported priority", body: `{"feed": "feedME", "priority": "urgent"}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		n, err := Parse(tt.body)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: Parse() = %s, want error", tt.name, n)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: Parse() error %v", tt.name, err)
+			continue
+		}
+		w := tt.want
+		if n.Version != w.Version || n.Feed != w.Feed || n.FeedUID != w.FeedUID || n.Priority != w.Priority ||
+			n.Requester != w.Requester || n.CorrelationID != w.CorrelationID {
+			t.Errorf("%s: Parse() = %s, want %s", tt.name, n, &w)
+		}
+	}
+}
+
+func TestParseEmpty(t *testing.T) {
+	if _, err := Parse("  \n"); !errors.Is(err, ErrEmpty) {
+		t.Errorf("Parse(empty) error %v, want %v", err, ErrEmpty)
+	}
+}
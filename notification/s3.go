@@ -0,0 +1,120 @@
+package notification
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+// S3 event notifications (ObjectCreated) are mapped to feeds by rules
+// rule "<bucket>/<key pattern>=<feed>" - key pattern uses path.Match syntax
+// feed "*" stands for object file name without extension, e.g.
+//   feeds-bucket/feeds/*.txt=*          feeds/feedME.txt -> feedME
+//   feeds-bucket/quic/latest.csv=quiccloud
+
+type S3Rule struct {
+	Bucket     string
+	KeyPattern string
+	Feed       string
+}
+
+// ParseS3Rules parses rule specifications
+func ParseS3Rules(specs []string) ([]S3Rule, error) {
+	rules := make([]S3Rule, 0, len(specs))
+	for _, spec := range specs {
+		pattern, feed, ok := strings.Cut(spec, "=")
+		bucket, keyPattern, okPattern := strings.Cut(strings.TrimSpace(pattern), "/")
+		feed = strings.TrimSpace(feed)
+		if !ok || !okPattern || bucket == "" || keyPattern == "" || feed == "" {
+			return nil, fmt.Errorf("invalid S3 feed rule %q, expecting <bucket>/<key pattern>=<feed>", spec)
+		}
+		if _, err := path.Match(keyPattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid key pattern in S3 feed rule %q: %w", spec, err)
+		}
+		rules = append(rules, S3Rule{Bucket: bucket, KeyPattern: keyPattern, Feed: feed})
+	}
+	return rules, nil
+}
+
+// Match returns feed name for object, empty if rule does not match
+func (r S3Rule) Match(bucket, key string) string {
+	if bucket != r.Bucket {
+		return ""
+	}
+	if ok, _ := path.Match(r.KeyPattern, key); !ok {
+		return ""
+	}
+	if r.Feed == "*" {
+		name := path.Base(key)
+		return strings.TrimSuffix(name, path.Ext(name))
+	}
+	return r.Feed
+}
+
+// S3Event as delivered by S3 event notifications
+type S3Event struct {
+	Records []S3EventRecord `json:"Records"`
+	Event   string          `json:"Event"` // s3:TestEvent sent when notification is configured
+}
+
+type S3EventRecord struct {
+	EventSource      string            `json:"eventSource"`
+	EventName        string            `json:"eventName"`
+	EventTime        string            `json:"eventTime"`
+	ResponseElements map[string]string `json:"responseElements"`
+	UserIdentity     map[string]string `json:"userIdentity"`
+	S3               struct {
+		Bucket struct {
+			Name string `json:"name"`
+		} `json:"bucket"`
+		Object struct {
+			Key       string `json:"key"`
+			Size      int64  `json:"size"`
+			ETag      string `json:"eTag"`
+			Sequencer string `json:"sequencer"`
+		} `json:"object"`
+	} `json:"s3"`
+}
+
+// objectKey returns URL-decoded object key
+func (r *S3EventRecord) objectKey() string {
+	key, err := url.QueryUnescape(r.S3.Object.Key)
+	if err != nil {
+		return r.S3.Object.Key
+	}
+	return key
+}
+
+// s3Notifications maps ObjectCreated records to notifications, records without matching rule are skipped
+func (d *Decoder) s3Notifications(event *S3Event) []*Notification {
+	notifications := make([]*Notification, 0, len(event.Records))
+	for _, record := range event.Records {
+		if record.EventSource != "aws:s3" || !strings.HasPrefix(record.EventName, "ObjectCreated:") {
+			continue
+		}
+
+		bucket, key := record.S3.Bucket.Name, record.objectKey()
+		feed := ""
+		for _, rule := range d.S3Rules {
+			if feed = rule.Match(bucket, key); feed != "" {
+				break
+			}
+		}
+		if feed == "" {
+			fmt.Fprintf(os.Stdout, "[Decode] No S3 feed rule matches s3://%s/%s\n", bucket, key)
+			continue
+		}
+
+		notifications = append(notifications, &Notification{
+			Version:       CurrentVersion,
+			Feed:          feed,
+			Priority:      PriorityNormal,
+			Requester:     record.UserIdentity["principalId"],
+			CorrelationID: record.ResponseElements["x-amz-request-id"],
+			Reason:        fmt.Sprintf("%s s3://%s/%s", record.EventName, bucket, key),
+		})
+	}
+	return notifications
+}
@@ -0,0 +1,46 @@
+package notification
+
+import (
+	"testing"
+)
+
+func TestParseS3Rules(t *testing.T) {
+	rules, err := ParseS3Rules([]string{"feeds-bucket/feeds/*.txt=*", " quic/latest.csv = quiccloud "})
+	if err != nil {
+		t.Fatalf("ParseS3Rules() error %v", err)
+	}
+	want := []S3Rule{
+		{Bucket: "feeds-bucket", KeyPattern: "feeds/*.txt", Feed: "*"},
+		{Bucket: "quic", KeyPattern: "latest.csv", Feed: "quiccloud"},
+	}
+	if len(rules) != len(want) || rules[0] != want[0] || rules[1] != want[1] {
+		t.Errorf("ParseS3Rules() = %+v, want %+v", rules, want)
+	}
+
+	for _, spec := range []string{"bucket/key", "bucket=feed", "/key=feed", "bucket/=feed", "bucket/key=", "bucket/[=feed"} {
+		if _, err := ParseS3Rules([]string{spec}); err == nil {
+			t.Errorf("ParseS3Rules(%q) accepted invalid rule", spec)
+		}
+	}
+}
+
+func TestS3RuleMatch(t *testing.T) {
+	tests := []struct {
+		rule        S3Rule
+		bucket, key string
+		want        string
+	}{
+		{S3Rule{"feeds-bucket", "feeds/*.txt", "*"}, "feeds-bucket", "feeds/feedME.txt", "feedME"},
+		{S3Rule{"feeds-bucket", "feeds/*.txt", "*"}, "feeds-bucket", "feeds/feedME.csv", ""},
+		{S3Rule{"feeds-bucket", "feeds/*.txt", "*"}, "feeds-bucket", "feeds/sub/feedME.txt", ""},
+		{S3Rule{"feeds-bucket", "feeds/*.txt", "*"}, "other-bucket", "feeds/feedME.txt", ""},
+		{S3Rule{"feeds-bucket", "feeds/*", "*"}, "feeds-bucket", "feeds/feed.v2.txt", "feed.v2"},
+		{S3Rule{"quic", "latest.csv", "quiccloud"}, "quic", "latest.csv", "quiccloud"},
+		{S3Rule{"quic", "latest.csv", "quiccloud"}, "quic", "previous.csv", ""},
+	}
+	for _, tt := range tests {
+		if got := tt.rule.Match(tt.bucket, tt.key); got != tt.want {
+			t.Errorf("%+v.Match(%q, %q) = %q, want %q", tt.rule, tt.bucket, tt.key, got, tt.want)
+		}
+	}
+}
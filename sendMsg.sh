@@ -25,6 +25,9 @@ while true; do
     send_message "feedME"
     sleep 2
 
+    send_message '{"version": 1, "feed": "feedME", "priority": "high", "requester": "sendMsg.sh", "reason": "testing"}'
+    sleep 2
+
     send_message "invalid message on $(date)"
     sleep 10; 
 done
\ No newline at end of file
@@ -0,0 +1,78 @@
+package sqsin
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// dedup remembers handled messages by deduplication ID, message with known ID
+// sent within window of the handled one is a duplicate
+// IDs are forgotten once window passed since the handled message was sent
+
+type dedup struct {
+	window time.Duration
+
+	mu   sync.Mutex
+	seen map[string]time.Time // deduplication ID -> sent time of handled message
+}
+
+func newDedup(window time.Duration) *dedup {
+	return &dedup{
+		window: window,
+		seen:   make(map[string]time.Time),
+	}
+}
+
+// Duplicate reports whether message with the ID was already handled
+func (d *dedup) Duplicate(id string, sent time.Time) bool {
+	if d.window <= 0 {
+		return false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	handledSent, ok := d.seen[id]
+	if !ok {
+		return false
+	}
+	return sent.Sub(handledSent).Abs() <= d.window
+}
+
+// Handled remembers message with the ID as handled
+func (d *dedup) Handled(id string, sent time.Time) {
+	if d.window <= 0 {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for seenID, seenSent := range d.seen {
+		if time.Since(seenSent) > d.window {
+			delete(d.seen, seenID)
+		}
+	}
+	d.seen[id] = sent
+}
+
+// deduplicationID returns FIFO queue's MessageDeduplicationId, message ID for standard queues
+func deduplicationID(msg *types.Message) string {
+	if id := msg.Attributes["MessageDeduplicationId"]; id != "" {
+		return id
+	}
+	return aws.ToString(msg.MessageId)
+}
+
+// sentTime returns SentTimestamp of message, now if unknown
+func sentTime(msg *types.Message) time.Time {
+	millis, err := strconv.ParseInt(msg.Attributes["SentTimestamp"], 10, 64)
+	if err != nil {
+		return time.Now()
+	}
+	return time.UnixMilli(millis)
+}
@@ -0,0 +1,78 @@
+package sqsin
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+func TestDedupDuplicate(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name   string
+		window time.Duration
+		id     string
+		sent   time.Time
+		want   bool
+	}{
+		{"same message", 5 * time.Minute, "m1", now, true},
+		{"resent inside window", 5 * time.Minute, "m1", now.Add(4 * time.Minute), true},
+		{"resent outside window", 5 * time.Minute, "m1", now.Add(6 * time.Minute), false},
+		{"sent before outside window", 5 * time.Minute, "m1", now.Add(-6 * time.Minute), false},
+		{"other message", 5 * time.Minute, "m2", now, false},
+		{"disabled", 0, "m1", now, false},
+		{"disabled by negative window", -time.Minute, "m1", now, false},
+	}
+	for _, tt := range tests {
+		d := newDedup(tt.window)
+		d.Handled("m1", now)
+		if got := d.Duplicate(tt.id, tt.sent); got != tt.want {
+			t.Errorf("%s: Duplicate(%q) = %v, want %v", tt.name, tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestDedupForgets(t *testing.T) {
+	d := newDedup(time.Minute)
+	d.Handled("old", time.Now().Add(-2*time.Minute))
+	d.Handled("new", time.Now())
+
+	if _, ok := d.seen["old"]; ok {
+		t.Errorf("message sent before window is remembered")
+	}
+	if _, ok := d.seen["new"]; !ok {
+		t.Errorf("message sent inside window is forgotten")
+	}
+
+	disabled := newDedup(0)
+	disabled.Handled("new", time.Now())
+	if len(disabled.seen) != 0 {
+		t.Errorf("disabled dedup remembers %d messages, want 0", len(disabled.seen))
+	}
+}
+
+func TestDeduplicationID(t *testing.T) {
+	tests := []struct {
+		msg  types.Message
+		want string
+	}{
+		{types.Message{MessageId: aws.String("id1")}, "id1"},
+		{types.Message{MessageId: aws.String("id1"), Attributes: map[string]string{"MessageDeduplicationId": "dedup1"}}, "dedup1"},
+	}
+	for _, tt := range tests {
+		if got := deduplicationID(&tt.msg); got != tt.want {
+			t.Errorf("deduplicationID(%v) = %q, want %q", tt.msg.Attributes, got, tt.want)
+		}
+	}
+}
+
+func TestSentTime(t *testing.T) {
+	sent := time.UnixMilli(1700000000123)
+	msg := types.Message{Attributes: map[string]string{"SentTimestamp": strconv.FormatInt(sent.UnixMilli(), 10)}}
+	if got := sentTime(&msg); !got.Equal(sent) {
+		t.Errorf("sentTime() = %v, want %v", got, sent)
+	}
+}
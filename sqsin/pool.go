@@ -0,0 +1,78 @@
+package sqsin
+
+import (
+	"sync"
+)
+
+// pool runs tasks on up to size goroutines at once
+// tasks with the same non-empty key start one after another in submission order - task returns
+// function finishing it (nil if none), which runs once next task of the key was started,
+// tasks waiting for their key occupy a slot too, so Submit blocks while size tasks are pending
+
+type pool struct {
+	slots chan struct{}
+
+	mu     sync.Mutex
+	queues map[string][]func() func() // key -> tasks waiting for running task of the key
+	wg     sync.WaitGroup
+}
+
+func newPool(size int) *pool {
+	return &pool{
+		slots:  make(chan struct{}, max(size, 1)),
+		queues: make(map[string][]func() func()),
+	}
+}
+
+// Free returns number of tasks which can be submitted without blocking
+func (p *pool) Free() int {
+	return cap(p.slots) - len(p.slots)
+}
+
+// Submit runs task once a slot is free, after earlier tasks of the same key returned
+func (p *pool) Submit(key string, task func() func()) {
+	p.slots <- struct{}{}
+	p.wg.Add(1)
+
+	if key == "" {
+		go p.run(key, task)
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if queue, busy := p.queues[key]; busy {
+		p.queues[key] = append(queue, task)
+		return
+	}
+	p.queues[key] = nil
+	go p.run(key, task)
+}
+
+// run runs task, starts next queued task of the key and then finishes task
+func (p *pool) run(key string, task func() func()) {
+	defer p.wg.Done()
+	defer func() { <-p.slots }()
+
+	finish := task()
+
+	if key != "" {
+		p.mu.Lock()
+		if queue := p.queues[key]; len(queue) > 0 {
+			p.queues[key] = queue[1:]
+			go p.run(key, queue[0])
+		} else {
+			delete(p.queues, key)
+		}
+		p.mu.Unlock()
+	}
+
+	if finish != nil {
+		finish()
+	}
+}
+
+// Wait waits for all submitted tasks
+func (p *pool) Wait() {
+	p.wg.Wait()
+}
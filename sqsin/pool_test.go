@@ -0,0 +1,126 @@
+package sqsin
+
+import (
+	"context"
+	"cpfeedman/kicker"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPoolKeyOrder(t *testing.T) {
+	p := newPool(4)
+
+	var mu sync.Mutex
+	var started []int
+	for i := range 6 {
+		p.Submit("feedME", func() func() {
+			mu.Lock()
+			started = append(started, i)
+			mu.Unlock()
+			time.Sleep(time.Millisecond)
+			return nil
+		})
+	}
+	p.Wait()
+
+	if want := []int{0, 1, 2, 3, 4, 5}; !slices.Equal(started, want) {
+		t.Errorf("tasks started in order %v, want %v", started, want)
+	}
+}
+
+func TestPoolBounded(t *testing.T) {
+	p := newPool(2)
+
+	var running, maxRunning atomic.Int32
+	release := make(chan struct{})
+	submitted := make(chan struct{})
+	go func() {
+		defer close(submitted)
+		for range 4 {
+			p.Submit("", func() func() {
+				n := running.Add(1)
+				for {
+					m := maxRunning.Load()
+					if n <= m || maxRunning.CompareAndSwap(m, n) {
+						break
+					}
+				}
+				<-release
+				running.Add(-1)
+				return nil
+			})
+		}
+	}()
+
+	select {
+	case <-submitted:
+		t.Fatal("Submit did not block while all slots were busy")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if free := p.Free(); free != 0 {
+		t.Errorf("Free() = %d, want 0", free)
+	}
+
+	close(release)
+	<-submitted
+	p.Wait()
+
+	if got := maxRunning.Load(); got != 2 {
+		t.Errorf("%d tasks ran at once, want 2", got)
+	}
+	if free := p.Free(); free != 2 {
+		t.Errorf("Free() = %d after Wait, want 2", free)
+	}
+}
+
+// finish functions run concurrently, next task of the key does not wait for them
+func TestPoolFinishDoesNotBlockKey(t *testing.T) {
+	p := newPool(4)
+
+	release := make(chan struct{})
+	var started atomic.Int32
+	for range 3 {
+		p.Submit("feedME", func() func() {
+			started.Add(1)
+			return func() { <-release }
+		})
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for started.Load() < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := started.Load(); got != 3 {
+		t.Errorf("%d tasks started while finish functions were blocked, want 3", got)
+	}
+
+	close(release)
+	p.Wait()
+}
+
+// notifications of a feed handled by ordered workers are merged into one kick
+func TestPoolCoalescing(t *testing.T) {
+	var kicks atomic.Int32
+	coalescer := kicker.NewCoalescer(context.Background(), 50*time.Millisecond, func(ctx context.Context, feed string, targets []string) (*kicker.Report, error) {
+		kicks.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		return nil, nil
+	})
+
+	p := newPool(4)
+	for range 3 {
+		p.Submit("feedME", func() func() {
+			resCh := coalescer.Submit("feedME", []string{"gw1"})
+			return func() { <-resCh }
+		})
+	}
+	p.Wait()
+
+	stats := coalescer.Stats()
+	if stats.Kicks != 1 || stats.Merged != 2 || kicks.Load() != 1 {
+		t.Errorf("coalescer stats %s, want kicks=1 merged=2", stats)
+	}
+}
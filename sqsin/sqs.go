@@ -2,8 +2,13 @@ package sqsin
 
 import (
 	"context"
+	"cpfeedman/input"
 	"fmt"
 	"log"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -12,77 +17,432 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
 )
 
-// SQSIn represents SQS input - consumes messages and executes callbac function with the message body
+// SQSIn represents SQS input - consumes messages and passes them to handler, implements input.Source
 
-// allows to define a callback function to handle messages -
-// swqIn.OnMessage = func(msg *types.Message) {
-// 	fmt.Fprintf(os.Stdout, "CALLBACK Received message: %s\n", *msg.Body
-// ...
+// handler is passed to Listen -
+// sqsIn.Listen(func(msg *input.Message) input.Outcome {
+// 	fmt.Fprintf(os.Stdout, "Received message: %s\n", msg.Body)
+// 	return input.Ack
+// })
+
+// queue is long polled for up to MaxMessages messages at once, acknowledged messages
+// are deleted together (DeleteMessageBatch)
+// up to Workers messages are handled concurrently, receiving waits while all workers are busy,
+// messages with the same OrderKey (e.g. feed) are submitted (Submit) one after another in receive order,
+// their outcomes are awaited concurrently, so notifications of a feed can still be merged into one kick
+//
+// FIFO queue is detected from queue attributes - messages of a MessageGroupId are handled in order
+// instead of OrderKey and when one of them is not deleted, its later messages already received are
+// released, so the group is redelivered in order
+// message whose MessageDeduplicationId (message ID in standard queue) was already handled is dropped
+// when sent within DedupWindow of the handled one (SentTimestamp)
+// visibility timeout of received message is extended until handler finishes, so long kicks are not redelivered
+// message is deleted only when handler returns Ack
+// Retry leaves message in queue to be redelivered after RetryDelay doubled with every receive (up to MaxRetryDelay)
+// Reject makes message visible immediately, so queue redrive policy moves it to dead-letter queue
+// after maxReceiveCount - without redrive policy rejected message is deleted
+// on Stop messages waiting for worker are released
 
 // expecting SQS queue URL
 // AWS is authenticated via environment variables
 
 type SQSIn struct {
-	QueueUrl  string                   // SQS queue URL
-	OnMessage func(msg *types.Message) // Callback function to handle received messages
+	QueueUrl        string                          // SQS queue URL
+	MaxMessages     int32                           // messages per receive, 1 to 10
+	WaitTimeSeconds int32                           // long polling wait, 0 to 20
+	Workers         int                             // messages handled concurrently
+	OrderKey        func(msg *input.Message) string // optional, messages with the same key are submitted in order
+	Submit          input.Submitter                 // optional, used instead of handler passed to Listen
+	RetryDelay      time.Duration                   // visibility of first retried message, doubled with every receive
+	MaxRetryDelay   time.Duration                   // SQS allows up to 12h
+	DedupWindow     time.Duration                   // messages with deduplication ID handled within window are dropped, 0 disables
+
+	input.Stopper
 }
 
 func NewSQSIn(queueUrl string) *SQSIn {
 	return &SQSIn{
-		QueueUrl:  queueUrl,
-		OnMessage: nil, // default to nil, can be set later
+		QueueUrl:        queueUrl,
+		MaxMessages:     10,
+		WaitTimeSeconds: 20,
+		Workers:         4,
+		RetryDelay:      30 * time.Second,
+		MaxRetryDelay:   15 * time.Minute,
+		DedupWindow:     5 * time.Minute,
 	}
 }
 
-func (s *SQSIn) Listen() error {
+// Name is SQS with queue name, e.g. SQS:cpfeedman
+func (s *SQSIn) Name() string {
+	return "SQS:" + path.Base(s.QueueUrl)
+}
+
+func (s *SQSIn) Listen(handler input.Handler) error {
 	// fmt.Println("Starting SQS Client...")
 
-	cfg, err := config.LoadDefaultConfig(context.TODO())
+	ctx, cancel := s.Listening()
+	defer cancel()
+
+	cfg, err := config.LoadDefaultConfig(ctx)
 	if err != nil {
-		log.Fatalf("[SQS] unable to load SDK config, %v", err)
+		return fmt.Errorf("unable to load SDK config: %w", err)
 	}
 
 	client := sqs.NewFromConfig(cfg)
 
 	fmt.Printf("[SQSIN] Client initialized with Queue URL: %s\n", s.QueueUrl)
 
-	ctx := context.TODO()
+	queue, err := s.queueAttributes(ctx, client)
+	if err != nil {
+		log.Printf("[SQSIN] unable to get queue attributes, rejected messages will be deleted: %v", err)
+	} else {
+		log.Printf("[SQSIN] Queue has dead-letter queue: %v, visibility timeout: %s, FIFO: %v",
+			queue.hasDeadLetterQueue, queue.visibility, queue.fifo)
+	}
+	duplicates := newDedup(s.DedupWindow)
+
+	submit := s.Submit
+	if submit == nil {
+		submit = handler.Submitter()
+	}
+
+	// received messages are settled even when receiving was stopped
+	settleCtx := context.Background()
+
+	deletes := make(chan types.Message, s.MaxMessages)
+	deleterDone := make(chan struct{})
+	go func() {
+		defer close(deleterDone)
+		s.deleteLoop(settleCtx, client, deletes)
+	}()
+
+	workers := newPool(s.Workers)
+	defer func() {
+		workers.Wait()
+		close(deletes)
+		<-deleterDone
+	}()
 
 	for {
+		// receive only as many messages as can be handled now
 		output, err := client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
 			QueueUrl:            aws.String(s.QueueUrl),
-			MaxNumberOfMessages: 1,
-			AttributeNames:      []types.QueueAttributeName{"SentTimestamp"},
+			MaxNumberOfMessages: min(s.MaxMessages, int32(max(workers.Free(), 1))),
+			WaitTimeSeconds:     s.WaitTimeSeconds,
+			AttributeNames: []types.QueueAttributeName{
+				"SentTimestamp", "ApproximateReceiveCount", "MessageGroupId", "MessageDeduplicationId",
+			},
+			MessageAttributeNames: []string{"All"},
 		})
+		if ctx.Err() != nil {
+			return nil // stopped
+		}
 		if err != nil {
 			log.Printf("[SQSIN] error receiving message: %v", err)
 			time.Sleep(5 * time.Second)
 			continue
 		}
 
-		if len(output.Messages) == 0 {
-			continue // no message
-		}
+		// FIFO message group whose message was not deleted - its later messages in this receive
+		// are released too, so the group is redelivered in order
+		var stalledMu sync.Mutex
+		stalled := make(map[string]bool)
 
+		// empty response after wait time simply polls again
 		for _, msg := range output.Messages {
 			log.Printf("[SQSIN] Received message: %s", aws.ToString(msg.Body))
 
-			// delegete to callback function if set
-			if s.OnMessage != nil {
-				s.OnMessage(&msg)
+			inMsg := &input.Message{
+				Source:     s.Name(),
+				ID:         aws.ToString(msg.MessageId),
+				Body:       aws.ToString(msg.Body),
+				Attributes: MessageAttributes(&msg),
 			}
+			// messages of FIFO group are handled in order, whatever feed they notify
+			group := msg.Attributes["MessageGroupId"]
+			key := ""
+			if queue.fifo {
+				key = "group:" + group
+			} else if s.OrderKey != nil {
+				key = s.OrderKey(inMsg)
+			}
+
+			// message stays invisible while waiting for worker and being handled
+			stopHeartbeat := s.heartbeat(settleCtx, client, &msg, queue.visibility)
+
+			// blocks while all workers are busy
+			workers.Submit(key, func() func() {
+				// stopping - messages still waiting for worker go back to queue
+				if ctx.Err() != nil {
+					stopHeartbeat()
+					s.releaseMessage(settleCtx, client, &msg)
+					return nil
+				}
+
+				dedupID, sent := deduplicationID(&msg), sentTime(&msg)
+				if duplicates.Duplicate(dedupID, sent) {
+					stopHeartbeat()
+					log.Printf("[SQSIN] Dropping duplicate message ID %s (deduplication ID %s)", aws.ToString(msg.MessageId), dedupID)
+					deletes <- msg
+					return nil
+				}
+
+				if queue.fifo {
+					stalledMu.Lock()
+					groupStalled := stalled[group]
+					stalledMu.Unlock()
+					if groupStalled {
+						stopHeartbeat()
+						log.Printf("[SQSIN] Releasing message ID %s, earlier message of group %s will be redelivered", aws.ToString(msg.MessageId), group)
+						s.releaseMessage(settleCtx, client, &msg)
+						return nil
+					}
+				}
+
+				wait := submit(inMsg)
+				settle := func() {
+					outcome := wait()
+					stopHeartbeat()
+
+					deleted := false
+					switch outcome {
+					case input.Ack:
+						duplicates.Handled(dedupID, sent)
+						deletes <- msg
+						deleted = true
+					case input.Retry:
+						// stopping - kick was not started or not followed to the end, message goes back to queue
+						if ctx.Err() != nil {
+							s.releaseMessage(settleCtx, client, &msg)
+							break
+						}
+						delay := s.retryDelay(&msg)
+						log.Printf("[SQSIN] Message ID %s will be redelivered in %s", aws.ToString(msg.MessageId), delay)
+						if err := s.changeVisibility(settleCtx, client, &msg, delay); err != nil {
+							log.Printf("[SQSIN] failed to delay message ID %s: %v", aws.ToString(msg.MessageId), err)
+						}
+					case input.Reject:
+						if queue.hasDeadLetterQueue {
+							s.releaseMessage(settleCtx, client, &msg)
+						} else {
+							log.Printf("[SQSIN] Rejected message ID %s, no dead-letter queue configured", aws.ToString(msg.MessageId))
+							deletes <- msg
+							deleted = true
+						}
+					}
 
-			// Delete message
-			_, err := client.DeleteMessage(ctx, &sqs.DeleteMessageInput{
-				QueueUrl:      aws.String(s.QueueUrl),
-				ReceiptHandle: msg.ReceiptHandle,
+					if queue.fifo && !deleted {
+						stalledMu.Lock()
+						stalled[group] = true
+						stalledMu.Unlock()
+					}
+				}
+
+				// next message of FIFO group waits for outcome of this one,
+				// messages of other keys are only submitted in order and settled concurrently
+				if queue.fifo {
+					settle()
+					return nil
+				}
+				return settle
 			})
-			if err != nil {
-				log.Printf("[SQSIN] failed to delete message: %v", err)
-			} else {
-				log.Printf("[SQSIN] Deleted message ID: %s", aws.ToString(msg.MessageId))
+		}
+	}
+
+}
+
+// deleteLoop deletes acknowledged messages in batches, batch is sent when full
+// or when no message was acknowledged for a second, until deletes is closed
+func (s *SQSIn) deleteLoop(ctx context.Context, client *sqs.Client, deletes <-chan types.Message) {
+	var batch []types.Message
+	flush := time.NewTimer(time.Second)
+	defer flush.Stop()
+
+	for {
+		select {
+		case msg, ok := <-deletes:
+			if !ok {
+				s.deleteMessages(ctx, client, batch)
+				return
+			}
+			batch = append(batch, msg)
+			if len(batch) >= 10 {
+				s.deleteMessages(ctx, client, batch)
+				batch = nil
 			}
+			flush.Reset(time.Second)
+		case <-flush.C:
+			s.deleteMessages(ctx, client, batch)
+			batch = nil
 		}
 	}
+}
 
+// MessageAttributes returns string message attributes by name
+func MessageAttributes(msg *types.Message) map[string]string {
+	attributes := make(map[string]string, len(msg.MessageAttributes))
+	for name, value := range msg.MessageAttributes {
+		if value.StringValue != nil {
+			attributes[name] = aws.ToString(value.StringValue)
+		}
+	}
+	return attributes
+}
+
+// deleteMessages deletes messages in batches of up to 10, entries failed for transient reasons
+// (not sender's fault) are tried again, others are logged and left to be redelivered
+func (s *SQSIn) deleteMessages(ctx context.Context, client *sqs.Client, msgs []types.Message) {
+	for len(msgs) > 0 {
+		batch := msgs[:min(len(msgs), 10)]
+		msgs = msgs[len(batch):]
+
+		for attempt := 1; len(batch) > 0; attempt++ {
+			batch = s.deleteMessageBatch(ctx, client, batch, attempt < 3)
+		}
+	}
+}
+
+// deleteMessageBatch returns messages to be tried again
+func (s *SQSIn) deleteMessageBatch(ctx context.Context, client *sqs.Client, batch []types.Message, retry bool) []types.Message {
+	entries := make([]types.DeleteMessageBatchRequestEntry, len(batch))
+	for i, msg := range batch {
+		entries[i] = types.DeleteMessageBatchRequestEntry{
+			Id:            aws.String(strconv.Itoa(i)),
+			ReceiptHandle: msg.ReceiptHandle,
+		}
+	}
+
+	output, err := client.DeleteMessageBatch(ctx, &sqs.DeleteMessageBatchInput{
+		QueueUrl: aws.String(s.QueueUrl),
+		Entries:  entries,
+	})
+	if err != nil {
+		log.Printf("[SQSIN] failed to delete %d messages: %v", len(batch), err)
+		if retry {
+			return batch
+		}
+		return nil
+	}
+
+	for _, entry := range output.Successful {
+		if i, err := strconv.Atoi(aws.ToString(entry.Id)); err == nil {
+			log.Printf("[SQSIN] Deleted message ID: %s", aws.ToString(batch[i].MessageId))
+		}
+	}
+
+	var again []types.Message
+	for _, entry := range output.Failed {
+		i, err := strconv.Atoi(aws.ToString(entry.Id))
+		if err != nil {
+			continue
+		}
+		msg := batch[i]
+		if !entry.SenderFault && retry {
+			again = append(again, msg)
+			continue
+		}
+		// e.g. expired receipt handle - message was or will be redelivered
+		log.Printf("[SQSIN] failed to delete message ID %s: %s %s",
+			aws.ToString(msg.MessageId), aws.ToString(entry.Code), aws.ToString(entry.Message))
+	}
+	return again
+}
+
+// heartbeat keeps message invisible by extending its visibility timeout every half of it
+// until returned stop function is called, stop waits for extension in progress,
+// so it does not override visibility set after it
+func (s *SQSIn) heartbeat(ctx context.Context, client *sqs.Client, msg *types.Message, visibility time.Duration) func() {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(max(visibility/2, time.Second))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := s.changeVisibility(ctx, client, msg, visibility); err != nil {
+					log.Printf("[SQSIN] failed to extend visibility of message ID %s: %v", aws.ToString(msg.MessageId), err)
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+		<-finished
+	}
+}
+
+// retryDelay doubles RetryDelay with every receive of message, up to MaxRetryDelay
+func (s *SQSIn) retryDelay(msg *types.Message) time.Duration {
+	receiveCount, err := strconv.Atoi(msg.Attributes["ApproximateReceiveCount"])
+	if err != nil || receiveCount < 1 {
+		receiveCount = 1
+	}
+
+	delay := s.RetryDelay
+	for i := 1; i < receiveCount && delay < s.MaxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, s.MaxRetryDelay)
+}
+
+// changeVisibility makes message visible again after timeout
+func (s *SQSIn) changeVisibility(ctx context.Context, client *sqs.Client, msg *types.Message, timeout time.Duration) error {
+	_, err := client.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          aws.String(s.QueueUrl),
+		ReceiptHandle:     msg.ReceiptHandle,
+		VisibilityTimeout: int32(timeout.Seconds()),
+	})
+	return err
+}
+
+// releaseMessage makes message visible again, so it is redelivered (or moved to dead-letter queue) immediately
+func (s *SQSIn) releaseMessage(ctx context.Context, client *sqs.Client, msg *types.Message) {
+	if err := s.changeVisibility(ctx, client, msg, 0); err != nil {
+		log.Printf("[SQSIN] failed to release message: %v", err)
+	} else {
+		log.Printf("[SQSIN] Released message ID: %s", aws.ToString(msg.MessageId))
+	}
+}
+
+// queueInfo holds queue attributes relevant to consuming
+type queueInfo struct {
+	hasDeadLetterQueue bool          // queue moves failing messages to dead-letter queue
+	visibility         time.Duration // visibility timeout
+	fifo               bool          // FIFO queue, messages have MessageGroupId and MessageDeduplicationId
+}
+
+// queueAttributes returns attributes of queue, FIFO is detected by queue name when attributes are unknown
+// (SQS default visibility 30s)
+func (s *SQSIn) queueAttributes(ctx context.Context, client *sqs.Client) (queueInfo, error) {
+	queue := queueInfo{
+		visibility: 30 * time.Second,
+		fifo:       strings.HasSuffix(s.QueueUrl, ".fifo"),
+	}
+
+	output, err := client.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl: aws.String(s.QueueUrl),
+		AttributeNames: []types.QueueAttributeName{
+			types.QueueAttributeNameRedrivePolicy,
+			types.QueueAttributeNameVisibilityTimeout,
+			types.QueueAttributeNameFifoQueue,
+		},
+	})
+	if err != nil {
+		return queue, err
+	}
+
+	queue.hasDeadLetterQueue = output.Attributes[string(types.QueueAttributeNameRedrivePolicy)] != ""
+	if seconds, err := strconv.Atoi(output.Attributes[string(types.QueueAttributeNameVisibilityTimeout)]); err == nil && seconds > 0 {
+		queue.visibility = time.Duration(seconds) * time.Second
+	}
+	if fifo, ok := output.Attributes[string(types.QueueAttributeNameFifoQueue)]; ok {
+		queue.fifo = fifo == "true"
+	}
+	return queue, nil
 }
@@ -0,0 +1,35 @@
+package sqsin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+func TestRetryDelay(t *testing.T) {
+	s := NewSQSIn("https://sqs.eu-west-1.amazonaws.com/123456789012/cpfeedman")
+	s.RetryDelay = 30 * time.Second
+	s.MaxRetryDelay = 5 * time.Minute
+
+	tests := []struct {
+		receiveCount string
+		want         time.Duration
+	}{
+		{"", 30 * time.Second},
+		{"invalid", 30 * time.Second},
+		{"0", 30 * time.Second},
+		{"1", 30 * time.Second},
+		{"2", time.Minute},
+		{"3", 2 * time.Minute},
+		{"4", 4 * time.Minute},
+		{"5", 5 * time.Minute},
+		{"100", 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		msg := types.Message{Attributes: map[string]string{"ApproximateReceiveCount": tt.receiveCount}}
+		if got := s.retryDelay(&msg); got != tt.want {
+			t.Errorf("retryDelay(ApproximateReceiveCount=%q) = %v, want %v", tt.receiveCount, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package webhook
+
+import (
+	"context"
+	"cpfeedman/input"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Webhook is HTTP input - POST /v1/notify delivers notification in request body, implements input.Source
+// request headers are passed to handler as message attributes (e.g. CloudEvents ce-* headers)
+//
+// requests are authenticated by bearer token (Authorization: Bearer <token>)
+// or HMAC-SHA256 of "<timestamp>.<body>" (X-Cpfeedman-Signature: sha256=<hex>, X-Cpfeedman-Timestamp: <unix seconds>),
+// at least one has to be configured - signed requests older or newer than SignatureTolerance are rejected to prevent replay
+// TLS is enabled when certificate and key files are set
+//
+// handler outcome is mapped to HTTP status - Ack 200, Retry 503, Reject 400
+
+const NotifyPath = "/v1/notify"
+
+const SignatureHeader = "X-Cpfeedman-Signature"
+
+const TimestampHeader = "X-Cpfeedman-Timestamp"
+
+type Webhook struct {
+	Addr         string // listen address, e.g. :8443
+	Token        string // bearer token
+	HMACSecret   string // shared secret for request signature
+	TLSCertFile  string
+	TLSKeyFile   string
+	MaxBodyBytes int64
+
+	SignatureTolerance time.Duration // accepted difference between signature timestamp and current time
+
+	handler input.Handler
+
+	mu      sync.Mutex
+	server  *http.Server
+	stopped bool // Stop called, possibly before Listen
+}
+
+func NewWebhook(addr string) *Webhook {
+	return &Webhook{
+		Addr:         addr,
+		MaxBodyBytes: 64 * 1024,
+
+		SignatureTolerance: 5 * time.Minute,
+	}
+}
+
+func (w *Webhook) Name() string {
+	return "Webhook"
+}
+
+func (w *Webhook) Listen(handler input.Handler) error {
+	if w.Token == "" && w.HMACSecret == "" {
+		return errors.New("webhook requires bearer token or HMAC secret")
+	}
+	w.handler = handler
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(NotifyPath, w.handleNotify)
+
+	server := &http.Server{
+		Addr:              w.Addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	w.mu.Lock()
+	if w.stopped {
+		w.mu.Unlock()
+		return nil
+	}
+	w.server = server
+	w.mu.Unlock()
+
+	err := w.listenAndServe(server)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil // stopped
+	}
+	return err
+}
+
+// Stop stops accepting requests and waits for requests in progress, Listen called after Stop returns immediately
+func (w *Webhook) Stop() {
+	w.mu.Lock()
+	w.stopped = true
+	server := w.server
+	w.mu.Unlock()
+
+	if server != nil {
+		if err := server.Shutdown(context.Background()); err != nil {
+			log.Printf("[WEBHOOK] failed to shut down: %v", err)
+		}
+	}
+}
+
+func (w *Webhook) listenAndServe(server *http.Server) error {
+	if w.TLSCertFile != "" || w.TLSKeyFile != "" {
+		log.Printf("[WEBHOOK] Listening on https://%s%s", w.Addr, NotifyPath)
+		return server.ListenAndServeTLS(w.TLSCertFile, w.TLSKeyFile)
+	}
+	log.Printf("[WEBHOOK] Listening on http://%s%s", w.Addr, NotifyPath)
+	return server.ListenAndServe()
+}
+
+type response struct {
+	Outcome string `json:"outcome"`
+	Error   string `json:"error,omitempty"`
+}
+
+func (w *Webhook) handleNotify(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		rw.Header().Set("Allow", http.MethodPost)
+		writeResponse(rw, http.StatusMethodNotAllowed, response{Error: "method not allowed"})
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(rw, req.Body, w.MaxBodyBytes))
+	if err != nil {
+		writeResponse(rw, http.StatusRequestEntityTooLarge, response{Error: "request body too large"})
+		return
+	}
+
+	if !w.authenticated(req, body) {
+		log.Printf("[WEBHOOK] Unauthorized request from %s", req.RemoteAddr)
+		writeResponse(rw, http.StatusUnauthorized, response{Error: "unauthorized"})
+		return
+	}
+
+	if strings.TrimSpace(string(body)) == "" {
+		writeResponse(rw, http.StatusBadRequest, response{Error: "empty notification"})
+		return
+	}
+
+	log.Printf("[WEBHOOK] Received notification from %s: %s", req.RemoteAddr, body)
+
+	outcome := w.handler(&input.Message{
+		Source:     w.Name(),
+		ID:         req.Header.Get("X-Request-Id"),
+		Body:       string(body),
+		Attributes: headerAttributes(req.Header),
+	})
+
+	switch outcome {
+	case input.Ack:
+		writeResponse(rw, http.StatusOK, response{Outcome: outcome.String()})
+	case input.Retry:
+		rw.Header().Set("Retry-After", "30")
+		writeResponse(rw, http.StatusServiceUnavailable, response{Outcome: outcome.String()})
+	default:
+		writeResponse(rw, http.StatusBadRequest, response{Outcome: outcome.String()})
+	}
+}
+
+// authenticated checks bearer token or signature of timestamp and body
+func (w *Webhook) authenticated(req *http.Request, body []byte) bool {
+	if w.Token != "" {
+		token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if ok && subtle.ConstantTimeCompare([]byte(token), []byte(w.Token)) == 1 {
+			return true
+		}
+	}
+
+	if w.HMACSecret != "" {
+		signature, ok := strings.CutPrefix(req.Header.Get(SignatureHeader), "sha256=")
+		received, err := hex.DecodeString(signature)
+		timestamp := req.Header.Get(TimestampHeader)
+		if ok && err == nil && w.recent(timestamp) {
+			if hmac.Equal(received, mac(w.HMACSecret, timestamp, body)) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// recent checks signature timestamp is within tolerance from current time
+func (w *Webhook) recent(timestamp string) bool {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	age := time.Since(time.Unix(seconds, 0))
+	return age.Abs() <= w.SignatureTolerance
+}
+
+// headerAttributes returns request headers with lowercase names, authentication headers are left out
+func headerAttributes(header http.Header) map[string]string {
+	attributes := make(map[string]string, len(header))
+	for name, values := range header {
+		name = strings.ToLower(name)
+		if name == "authorization" || name == strings.ToLower(SignatureHeader) || name == strings.ToLower(TimestampHeader) || len(values) == 0 {
+			continue
+		}
+		attributes[name] = values[0]
+	}
+	return attributes
+}
+
+func writeResponse(rw http.ResponseWriter, status int, resp response) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	if err := json.NewEncoder(rw).Encode(resp); err != nil {
+		log.Printf("[WEBHOOK] failed to write response: %v", err)
+	}
+}
+
+// Sign returns signature header value for timestamp header value and body, helper for publishers and testing
+func Sign(secret string, timestamp string, body []byte) string {
+	return fmt.Sprintf("sha256=%x", mac(secret, timestamp, body))
+}
+
+func mac(secret string, timestamp string, body []byte) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(timestamp + "."))
+	h.Write(body)
+	return h.Sum(nil)
+}
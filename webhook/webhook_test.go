@@ -0,0 +1,160 @@
+package webhook
+
+import (
+	"cpfeedman/input"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testToken  = "token"
+	testSecret = "secret"
+	testBody   = `{"feed": "feedME"}`
+)
+
+func newTestWebhook(outcome input.Outcome) (*Webhook, *int) {
+	calls := 0
+	w := NewWebhook(":0")
+	w.Token = testToken
+	w.HMACSecret = testSecret
+	w.handler = func(msg *input.Message) input.Outcome {
+		calls++
+		return outcome
+	}
+	return w, &calls
+}
+
+func signed(req *http.Request, secret string, sent time.Time, body string) *http.Request {
+	timestamp := strconv.FormatInt(sent.Unix(), 10)
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, Sign(secret, timestamp, []byte(body)))
+	return req
+}
+
+func TestHandleNotifyAuthentication(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name       string
+		request    func() *http.Request
+		wantStatus int
+	}{
+		{"bearer token", func() *http.Request {
+			req := httptest.NewRequest(http.MethodPost, NotifyPath, strings.NewReader(testBody))
+			req.Header.Set("Authorization", "Bearer "+testToken)
+			return req
+		}, http.StatusOK},
+		{"wrong bearer token", func() *http.Request {
+			req := httptest.NewRequest(http.MethodPost, NotifyPath, strings.NewReader(testBody))
+			req.Header.Set("Authorization", "Bearer other")
+			return req
+		}, http.StatusUnauthorized},
+		{"no credentials", func() *http.Request {
+			return httptest.NewRequest(http.MethodPost, NotifyPath, strings.NewReader(testBody))
+		}, http.StatusUnauthorized},
+		{"good signature", func() *http.Request {
+			req := httptest.NewRequest(http.MethodPost, NotifyPath, strings.NewReader(testBody))
+			return signed(req, testSecret, now, testBody)
+		}, http.StatusOK},
+		{"signature with wrong secret", func() *http.Request {
+			req := httptest.NewRequest(http.MethodPost, NotifyPath, strings.NewReader(testBody))
+			return signed(req, "other", now, testBody)
+		}, http.StatusUnauthorized},
+		{"signature of other body", func() *http.Request {
+			req := httptest.NewRequest(http.MethodPost, NotifyPath, strings.NewReader(testBody))
+			return signed(req, testSecret, now, `{"feed": "other"}`)
+		}, http.StatusUnauthorized},
+		{"signature with changed timestamp", func() *http.Request {
+			req := httptest.NewRequest(http.MethodPost, NotifyPath, strings.NewReader(testBody))
+			signed(req, testSecret, now, testBody)
+			req.Header.Set(TimestampHeader, strconv.FormatInt(now.Unix()+1, 10))
+			return req
+		}, http.StatusUnauthorized},
+		{"signature without timestamp", func() *http.Request {
+			req := httptest.NewRequest(http.MethodPost, NotifyPath, strings.NewReader(testBody))
+			signed(req, testSecret, now, testBody)
+			req.Header.Del(TimestampHeader)
+			return req
+		}, http.StatusUnauthorized},
+		{"stale signature", func() *http.Request {
+			req := httptest.NewRequest(http.MethodPost, NotifyPath, strings.NewReader(testBody))
+			return signed(req, testSecret, now.Add(-10*time.Minute), testBody)
+		}, http.StatusUnauthorized},
+		{"signature from future", func() *http.Request {
+			req := httptest.NewRequest(http.MethodPost, NotifyPath, strings.NewReader(testBody))
+			return signed(req, testSecret, now.Add(10*time.Minute), testBody)
+		}, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		w, calls := newTestWebhook(input.Ack)
+		rec := httptest.NewRecorder()
+		w.handleNotify(rec, tt.request())
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.wantStatus)
+		}
+		wantCalls := 0
+		if tt.wantStatus == http.StatusOK {
+			wantCalls = 1
+		}
+		if *calls != wantCalls {
+			t.Errorf("%s: handler called %d times, want %d", tt.name, *calls, wantCalls)
+		}
+	}
+}
+
+func TestHandleNotifyRequest(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		wantStatus int
+	}{
+		{"wrong method", http.MethodGet, "", http.StatusMethodNotAllowed},
+		{"oversized body", http.MethodPost, `{"feed": "` + strings.Repeat("x", 64*1024) + `"}`, http.StatusRequestEntityTooLarge},
+		{"empty body", http.MethodPost, " ", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		w, calls := newTestWebhook(input.Ack)
+		req := httptest.NewRequest(tt.method, NotifyPath, strings.NewReader(tt.body))
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rec := httptest.NewRecorder()
+		w.handleNotify(rec, req)
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.wantStatus)
+		}
+		if *calls != 0 {
+			t.Errorf("%s: handler called %d times, want 0", tt.name, *calls)
+		}
+	}
+}
+
+func TestHandleNotifyOutcome(t *testing.T) {
+	tests := []struct {
+		outcome        input.Outcome
+		wantStatus     int
+		wantRetryAfter string
+	}{
+		{input.Ack, http.StatusOK, ""},
+		{input.Retry, http.StatusServiceUnavailable, "30"},
+		{input.Reject, http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		w, _ := newTestWebhook(tt.outcome)
+		req := httptest.NewRequest(http.MethodPost, NotifyPath, strings.NewReader(testBody))
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rec := httptest.NewRecorder()
+		w.handleNotify(rec, req)
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%v: status = %d, want %d", tt.outcome, rec.Code, tt.wantStatus)
+		}
+		if got := rec.Header().Get("Retry-After"); got != tt.wantRetryAfter {
+			t.Errorf("%v: Retry-After = %q, want %q", tt.outcome, got, tt.wantRetryAfter)
+		}
+		if !strings.Contains(rec.Body.String(), `"outcome":"`+tt.outcome.String()+`"`) {
+			t.Errorf("%v: body = %s, want outcome %s", tt.outcome, rec.Body.String(), tt.outcome)
+		}
+	}
+}